@@ -0,0 +1,110 @@
+// Package codeowners parses and lints GitHub CODEOWNERS files
+package codeowners
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Locations are the paths GitHub reads a CODEOWNERS file from, only the first file found is honoured
+var Locations = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// Rule is a single pattern of a CODEOWNERS file and the owners assigned to it
+type Rule struct {
+	Line    int
+	Pattern string
+	Owners  []string
+	// Comment is the trailing comment of the line (without the #)
+	Comment string
+}
+
+// Comment is a line of a CODEOWNERS file that only contains a comment
+type Comment struct {
+	Line int
+	Text string
+}
+
+// File is a parsed CODEOWNERS file
+type File struct {
+	Path     string
+	Lines    []string
+	Rules    []Rule
+	Comments []Comment
+	Errors   []Finding
+}
+
+var (
+	userOwner  = regexp.MustCompile(`^@[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?$`)
+	teamOwner  = regexp.MustCompile(`^@[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?/[A-Za-z0-9_.-]+$`)
+	emailOwner = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+)
+
+// Parse reads the content of a CODEOWNERS file, lines that cannot be parsed are reported in File.Errors
+func Parse(path string, content string) *File {
+	f := &File{Path: path}
+
+	// an empty file has no lines, rather than a single empty one
+	if content != "" {
+		f.Lines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	}
+
+	for i, raw := range f.Lines {
+		lineNo := i + 1
+		line := strings.TrimSpace(raw)
+
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			f.Comments = append(f.Comments, Comment{Line: lineNo, Text: strings.TrimPrefix(line, "#")})
+			continue
+		}
+
+		rule := Rule{Line: lineNo}
+		if idx := strings.Index(line, " #"); idx >= 0 {
+			rule.Comment = strings.TrimSpace(line[idx+2:])
+			line = strings.TrimSpace(line[:idx])
+		}
+
+		fields := strings.Fields(line)
+		rule.Pattern = fields[0]
+
+		for _, owner := range fields[1:] {
+			if !ValidOwner(owner) {
+				f.Errors = append(f.Errors, f.finding(Error, lineNo, fmt.Sprintf("%q is not a valid owner, expected @user, @org/team or an email address", owner)))
+				continue
+			}
+			rule.Owners = append(rule.Owners, owner)
+		}
+
+		f.Rules = append(f.Rules, rule)
+	}
+
+	return f
+}
+
+// ValidOwner returns whether the owner is a @user, @org/team or email address
+func ValidOwner(owner string) bool {
+	return userOwner.MatchString(owner) || teamOwner.MatchString(owner) || emailOwner.MatchString(owner)
+}
+
+// IsTeam returns whether the owner is an @org/team reference
+func IsTeam(owner string) bool {
+	return teamOwner.MatchString(owner)
+}
+
+// IsUser returns whether the owner is an @user reference
+func IsUser(owner string) bool {
+	return userOwner.MatchString(owner)
+}
+
+func (f *File) finding(severity Severity, line int, message string) Finding {
+	return Finding{
+		Path:     f.Path,
+		Line:     line,
+		Severity: severity,
+		Message:  message,
+	}
+}
@@ -0,0 +1,143 @@
+package codeowners
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		rules    []Rule
+		comments []Comment
+		errors   int
+	}{
+		{
+			name:    "empty",
+			content: "",
+		},
+		{
+			name:    "rules and comments",
+			content: "# owners\n\n*       @org/core\n/docs/ @org/docs @octocat docs@example.com\n",
+			rules: []Rule{
+				{Line: 3, Pattern: "*", Owners: []string{"@org/core"}},
+				{Line: 4, Pattern: "/docs/", Owners: []string{"@org/docs", "@octocat", "docs@example.com"}},
+			},
+			comments: []Comment{{Line: 1, Text: " owners"}},
+		},
+		{
+			name:    "trailing comment",
+			content: "*.go @org/go # go files\n",
+			rules:   []Rule{{Line: 1, Pattern: "*.go", Owners: []string{"@org/go"}, Comment: "go files"}},
+		},
+		{
+			name:    "pattern without owners",
+			content: "/vendor/\n",
+			rules:   []Rule{{Line: 1, Pattern: "/vendor/"}},
+		},
+		{
+			name:    "invalid owner",
+			content: "* @org/core not-an-owner\n",
+			rules:   []Rule{{Line: 1, Pattern: "*", Owners: []string{"@org/core"}}},
+			errors:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := Parse("CODEOWNERS", tt.content)
+
+			if !reflect.DeepEqual(f.Rules, tt.rules) {
+				t.Errorf("rules = %+v, want %+v", f.Rules, tt.rules)
+			}
+			if !reflect.DeepEqual(f.Comments, tt.comments) {
+				t.Errorf("comments = %+v, want %+v", f.Comments, tt.comments)
+			}
+			if len(f.Errors) != tt.errors {
+				t.Errorf("errors = %v, want %d", f.Errors, tt.errors)
+			}
+			if got := string(f.Bytes()); got != tt.content {
+				t.Errorf("Bytes() = %q, want %q", got, tt.content)
+			}
+		})
+	}
+}
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []Finding
+	}{
+		{
+			name:    "clean",
+			content: "* @org/core\n/docs/ @org/docs\n",
+		},
+		{
+			name:    "duplicated pattern",
+			content: "/docs/ @org/docs\n/docs/ @org/core\n",
+			want: []Finding{
+				{Path: "CODEOWNERS", Line: 1, Severity: Warning, Message: `pattern "/docs/" is duplicated on line 2, only the last one takes effect`},
+			},
+		},
+		{
+			name:    "shadowed by catch-all",
+			content: "/docs/ @org/docs\n* @org/core\n",
+			want: []Finding{
+				{Path: "CODEOWNERS", Line: 1, Severity: Warning, Message: `pattern "/docs/" is shadowed by "*" on line 2`},
+			},
+		},
+		{
+			name:    "shadowed by anchored directory",
+			content: "/docs/api/ @org/api\n/docs/ @org/docs\n",
+			want: []Finding{
+				{Path: "CODEOWNERS", Line: 1, Severity: Warning, Message: `pattern "/docs/api/" is shadowed by "/docs/" on line 2`},
+			},
+		},
+		{
+			name:    "unanchored directory is not compared",
+			content: "/docs/api/ @org/api\ndocs/ @org/docs\n",
+		},
+		{
+			name:    "wildcard directory is not compared",
+			content: "/docs/api/ @org/api\n/docs/* @org/docs\n",
+		},
+		{
+			name:    "unsupported patterns",
+			content: "!/docs/ @org/docs\n[ab].go @org/go\n",
+			want: []Finding{
+				{Path: "CODEOWNERS", Line: 1, Severity: Error, Message: `pattern "!/docs/" uses negation which CODEOWNERS does not support`},
+				{Path: "CODEOWNERS", Line: 2, Severity: Error, Message: `pattern "[ab].go" uses a character range which CODEOWNERS does not support`},
+			},
+		},
+		{
+			name:    "invalid owner",
+			content: "* nobody\n",
+			want: []Finding{
+				{Path: "CODEOWNERS", Line: 1, Severity: Error, Message: `"nobody" is not a valid owner, expected @user, @org/team or an email address`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Lint(Parse("CODEOWNERS", tt.content))
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lint() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLintLocations(t *testing.T) {
+	got := LintLocations([]string{"CODEOWNERS", ".github/CODEOWNERS"})
+	want := []Finding{{Path: "CODEOWNERS", Severity: Warning, Message: "ignored by GitHub since .github/CODEOWNERS takes precedence"}}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LintLocations() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,93 @@
+package codeowners
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// Coverage reports which files of a repo have an owner
+type Coverage struct {
+	Total int
+	Owned int
+	// UnownedDirs are the top most directories in which no file has an owner ("/" for the root)
+	UnownedDirs []string
+	// UnownedFiles are the files without an owner
+	UnownedFiles []string
+}
+
+// Percent returns the percentage of files that have an owner
+func (c *Coverage) Percent() float64 {
+	if c.Total == 0 {
+		return 100
+	}
+	return float64(c.Owned) * 100 / float64(c.Total)
+}
+
+// CoverageOf evaluates every file path against the rules of the file, a file is owned when the last
+// rule matching it assigns at least one owner
+func CoverageOf(f *File, paths []string) *Coverage {
+	c := &Coverage{Total: len(paths)}
+
+	// count files and owned files of every directory
+	total := map[string]int{}
+	owned := map[string]int{}
+
+	for _, p := range paths {
+		rule, ok := f.OwnersOf(p)
+		isOwned := ok && len(rule.Owners) > 0
+
+		if isOwned {
+			c.Owned++
+		} else {
+			c.UnownedFiles = append(c.UnownedFiles, p)
+		}
+
+		for _, dir := range parents(p) {
+			total[dir]++
+			if isOwned {
+				owned[dir]++
+			}
+		}
+	}
+
+	for dir, count := range total {
+		if owned[dir] > 0 {
+			continue
+		}
+
+		// only report the top most directory
+		parent := parentKey(path.Dir(strings.TrimSuffix(dir, "/")))
+		if dir != "/" && owned[parent] == 0 {
+			continue
+		}
+
+		if count > 0 {
+			c.UnownedDirs = append(c.UnownedDirs, dir)
+		}
+	}
+
+	sort.Strings(c.UnownedDirs)
+	return c
+}
+
+// parents returns every directory the file is in, from the root down
+func parents(file string) []string {
+	dirs := []string{"/"}
+	dir := path.Dir(file)
+	var nested []string
+	for dir != "." && dir != "/" {
+		nested = append([]string{"/" + dir + "/"}, nested...)
+		dir = path.Dir(dir)
+	}
+	return append(dirs, nested...)
+}
+
+// parentKey converts the result of path.Dir of a directory key back to a directory key
+func parentKey(dir string) string {
+	dir = path.Clean(dir)
+	if dir == "/" || dir == "." {
+		return "/"
+	}
+	return dir + "/"
+}
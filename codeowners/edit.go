@@ -0,0 +1,108 @@
+package codeowners
+
+import (
+	"strings"
+)
+
+// Clone returns a copy of the file which can be edited without changing the original
+func (f *File) Clone() *File {
+	return Parse(f.Path, string(f.Bytes()))
+}
+
+// SetOwners replaces the owners of the rule on the line, the pattern and any trailing comment are preserved
+func (f *File) SetOwners(line int, owners []string) {
+	for i, rule := range f.Rules {
+		if rule.Line != line {
+			continue
+		}
+
+		f.Rules[i].Owners = owners
+		f.Lines[line-1] = formatRule(f.Rules[i])
+		return
+	}
+}
+
+// Bytes returns the content of the file, including any edits
+func (f *File) Bytes() []byte {
+	if len(f.Lines) == 0 {
+		return []byte{}
+	}
+	return []byte(strings.Join(f.Lines, "\n") + "\n")
+}
+
+func formatRule(rule Rule) string {
+	line := strings.Join(append([]string{rule.Pattern}, rule.Owners...), " ")
+	if rule.Comment != "" {
+		line += " # " + rule.Comment
+	}
+	return line
+}
+
+// EnsureCatchAll ensures the owner is an owner of the catch-all (*) rule, a catch-all rule is added before
+// any other rule when there is none. Returns false when the owner was already present
+func (f *File) EnsureCatchAll(owner string) bool {
+	// the last catch-all rule is the one that takes effect
+	for i := len(f.Rules) - 1; i >= 0; i-- {
+		rule := f.Rules[i]
+		if rule.Pattern != "*" {
+			continue
+		}
+
+		if HasOwner(rule.Owners, owner) {
+			return false
+		}
+
+		f.SetOwners(rule.Line, append(append([]string{}, rule.Owners...), owner))
+		return true
+	}
+
+	// a catch-all rule has to come first, otherwise it would override every rule before it
+	line := len(f.Lines) + 1
+	if len(f.Rules) > 0 {
+		line = f.Rules[0].Line
+	}
+	f.insertRule(line, Rule{Pattern: "*", Owners: []string{owner}})
+	return true
+}
+
+// HasOwner returns whether the owner is one of the owners, owners are compared case insensitively
+func HasOwner(owners []string, owner string) bool {
+	for _, o := range owners {
+		if strings.EqualFold(o, owner) {
+			return true
+		}
+	}
+	return false
+}
+
+// insertRule inserts the rule at the line, moving every line after it down
+func (f *File) insertRule(line int, rule Rule) {
+	rule.Line = line
+
+	lines := append([]string{}, f.Lines[:line-1]...)
+	lines = append(lines, formatRule(rule))
+	f.Lines = append(lines, f.Lines[line-1:]...)
+
+	var rules []Rule
+	inserted := false
+	for _, r := range f.Rules {
+		if r.Line >= line {
+			if !inserted {
+				rules = append(rules, rule)
+				inserted = true
+			}
+			r.Line++
+		}
+		rules = append(rules, r)
+	}
+	if !inserted {
+		rules = append(rules, rule)
+	}
+	f.Rules = rules
+
+	for i := range f.Comments {
+		if f.Comments[i].Line >= line {
+			f.Comments[i].Line++
+		}
+	}
+}
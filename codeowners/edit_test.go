@@ -0,0 +1,64 @@
+package codeowners
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnsureCatchAll(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		changed bool
+		want    string
+	}{
+		{
+			name:    "empty file",
+			content: "",
+			changed: true,
+			want:    "* @org/core\n",
+		},
+		{
+			name:    "comments only",
+			content: "# owners\n",
+			changed: true,
+			want:    "# owners\n* @org/core\n",
+		},
+		{
+			name:    "added before the first rule",
+			content: "# owners\n/docs/ @org/docs\n",
+			changed: true,
+			want:    "# owners\n* @org/core\n/docs/ @org/docs\n",
+		},
+		{
+			name:    "added to the last catch-all rule",
+			content: "* @org/old\n/docs/ @org/docs\n* @org/qa # everything\n",
+			changed: true,
+			want:    "* @org/old\n/docs/ @org/docs\n* @org/qa @org/core # everything\n",
+		},
+		{
+			name:    "already an owner",
+			content: "* @Org/Core\n",
+			changed: false,
+			want:    "* @Org/Core\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := Parse("CODEOWNERS", tt.content)
+
+			if changed := f.EnsureCatchAll("@org/core"); changed != tt.changed {
+				t.Errorf("EnsureCatchAll() = %v, want %v", changed, tt.changed)
+			}
+			if got := string(f.Bytes()); got != tt.want {
+				t.Errorf("content = %q, want %q", got, tt.want)
+			}
+
+			// the edited file parses to the same rules
+			if !reflect.DeepEqual(Parse("CODEOWNERS", tt.want).Rules, f.Rules) {
+				t.Errorf("rules = %+v, want %+v", f.Rules, Parse("CODEOWNERS", tt.want).Rules)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package codeowners
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity of a finding
+type Severity string
+
+// Severities of findings
+const (
+	Error   Severity = "ERROR"
+	Warning Severity = "WARN"
+)
+
+// Finding is a problem found in a CODEOWNERS file
+type Finding struct {
+	Path     string
+	Line     int
+	Severity Severity
+	Message  string
+}
+
+func (f Finding) String() string {
+	if f.Line == 0 {
+		return fmt.Sprintf("%s: %s", f.Path, f.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", f.Path, f.Line, f.Message)
+}
+
+// Lint returns the syntax errors of the file along with any unsupported patterns and rules that
+// never take effect because a later rule overrides them (GitHub applies the last matching rule)
+func Lint(f *File) []Finding {
+	findings := append([]Finding{}, f.Errors...)
+
+	for i, rule := range f.Rules {
+		if msg := unsupportedPattern(rule.Pattern); msg != "" {
+			findings = append(findings, f.finding(Error, rule.Line, msg))
+		}
+
+		for _, later := range f.Rules[i+1:] {
+			if later.Pattern == rule.Pattern {
+				findings = append(findings, f.finding(Warning, rule.Line, fmt.Sprintf("pattern %q is duplicated on line %d, only the last one takes effect", rule.Pattern, later.Line)))
+				break
+			}
+
+			if shadows(later.Pattern, rule.Pattern) {
+				findings = append(findings, f.finding(Warning, rule.Line, fmt.Sprintf("pattern %q is shadowed by %q on line %d", rule.Pattern, later.Pattern, later.Line)))
+				break
+			}
+		}
+	}
+
+	return findings
+}
+
+// LintLocations reports CODEOWNERS files that are ignored because a file in an earlier location exists,
+// the paths should be the locations the files were found in
+func LintLocations(paths []string) []Finding {
+	var findings []Finding
+
+	var active string
+	for _, loc := range Locations {
+		for _, path := range paths {
+			if path != loc {
+				continue
+			}
+
+			if active == "" {
+				active = path
+				continue
+			}
+
+			findings = append(findings, Finding{
+				Path:     path,
+				Severity: Warning,
+				Message:  fmt.Sprintf("ignored by GitHub since %s takes precedence", active),
+			})
+		}
+	}
+
+	return findings
+}
+
+// unsupportedPattern returns why the pattern is not supported by CODEOWNERS, or an empty string if it is
+func unsupportedPattern(pattern string) string {
+	switch {
+	case strings.HasPrefix(pattern, "!"):
+		return fmt.Sprintf("pattern %q uses negation which CODEOWNERS does not support", pattern)
+	case strings.HasPrefix(pattern, `\#`):
+		return fmt.Sprintf("pattern %q escapes a # which CODEOWNERS does not support", pattern)
+	case strings.ContainsAny(pattern, "[]"):
+		return fmt.Sprintf("pattern %q uses a character range which CODEOWNERS does not support", pattern)
+	}
+	return ""
+}
+
+// shadows returns whether every path matched by the earlier pattern is also matched by the later pattern,
+// this is a conservative check that only detects catch-all patterns and anchored directories
+func shadows(later, earlier string) bool {
+	switch later {
+	case "*", "**", "/**", "/":
+		return true
+	}
+
+	// only anchored patterns (or patterns with a slash in the middle, which git treats as anchored)
+	// can safely be compared by prefix
+	if !anchored(later) || !anchored(earlier) {
+		return false
+	}
+
+	dir := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(later, "/"), "**"), "/")
+	if dir == "" || strings.ContainsAny(dir, "*?") {
+		return false
+	}
+
+	return strings.HasPrefix(strings.TrimPrefix(earlier, "/"), dir+"/")
+}
+
+// anchored returns whether the pattern only matches relative to the root of the repo
+func anchored(pattern string) bool {
+	return strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+}
@@ -0,0 +1,86 @@
+package codeowners
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	patternsMu sync.Mutex
+	patterns   = map[string]*regexp.Regexp{}
+)
+
+// Match returns whether the path of a file (relative to the root of the repo) is matched by the rule
+func (r Rule) Match(path string) bool {
+	return compilePattern(r.Pattern).MatchString(strings.TrimPrefix(path, "/"))
+}
+
+// OwnersOf returns the rule that assigns owners to the path, GitHub applies the last matching rule.
+// Returns false if no rule matches the path
+func (f *File) OwnersOf(path string) (Rule, bool) {
+	for i := len(f.Rules) - 1; i >= 0; i-- {
+		if f.Rules[i].Match(path) {
+			return f.Rules[i], true
+		}
+	}
+	return Rule{}, false
+}
+
+// compilePattern converts a CODEOWNERS pattern (which follows most of the gitignore rules) to a regex
+func compilePattern(pattern string) *regexp.Regexp {
+	patternsMu.Lock()
+	defer patternsMu.Unlock()
+
+	if re, ok := patterns[pattern]; ok {
+		return re
+	}
+
+	p := pattern
+	dirOnly := strings.HasSuffix(p, "/")
+	p = strings.TrimSuffix(p, "/")
+
+	// a slash at the start or in the middle anchors the pattern to the root of the repo
+	anchoredPattern := strings.Contains(p, "/")
+	p = strings.TrimPrefix(p, "/")
+
+	var expr strings.Builder
+	if anchoredPattern {
+		expr.WriteString("^")
+	} else {
+		expr.WriteString("^(?:.*/)?")
+	}
+
+	for i := 0; i < len(p); i++ {
+		switch {
+		case strings.HasPrefix(p[i:], "**/"):
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(p[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case p[i] == '*':
+			expr.WriteString("[^/]*")
+		case p[i] == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(p[i])))
+		}
+	}
+
+	switch {
+	case dirOnly:
+		// only the contents of a directory are matched
+		expr.WriteString("/.*$")
+	case strings.HasSuffix(p, "/*"):
+		// dir/* only matches the files directly in the directory
+		expr.WriteString("$")
+	default:
+		// the pattern can match a file, or a directory and everything in it
+		expr.WriteString("(?:/.*)?$")
+	}
+
+	re := regexp.MustCompile(expr.String())
+	patterns[pattern] = re
+	return re
+}
@@ -0,0 +1,67 @@
+package codeowners
+
+import "testing"
+
+func TestRuleMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*", "README.md", true},
+		{"*", "a/b/c.go", true},
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", true},
+		{"*.go", "main.go.txt", false},
+		{"/docs/", "docs/index.md", true},
+		{"/docs/", "docs/api/index.md", true},
+		{"/docs/", "src/docs/index.md", false},
+		{"/docs/", "docs", false},
+		{"docs/", "docs/index.md", true},
+		{"docs/", "src/docs/index.md", true},
+		{"docs/*", "docs/index.md", true},
+		{"docs/*", "docs/api/index.md", false},
+		{"/build/logs/", "build/logs/out.log", true},
+		{"/build/logs/", "src/build/logs/out.log", false},
+		{"**/logs", "logs/out.log", true},
+		{"**/logs", "deep/down/logs/out.log", true},
+		{"/docs/**", "docs/api/index.md", true},
+		{"/docs/**", "src/docs/index.md", false},
+		{"apps", "apps/web/main.go", true},
+		{"apps", "src/apps/main.go", true},
+		{"a?c.txt", "abc.txt", true},
+		{"a?c.txt", "a/c.txt", false},
+		{"/README.md", "/README.md", true},
+		{"/README.md", "docs/README.md", false},
+	}
+
+	for _, tt := range tests {
+		if got := (Rule{Pattern: tt.pattern}).Match(tt.path); got != tt.want {
+			t.Errorf("Rule{%q}.Match(%q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestOwnersOf(t *testing.T) {
+	f := Parse("CODEOWNERS", "* @org/core\n/docs/ @org/docs\n/docs/internal/\n")
+
+	tests := []struct {
+		path string
+		line int
+	}{
+		{"main.go", 1},
+		{"docs/index.md", 2},
+		{"docs/internal/notes.md", 3},
+	}
+
+	for _, tt := range tests {
+		rule, ok := f.OwnersOf(tt.path)
+		if !ok || rule.Line != tt.line {
+			t.Errorf("OwnersOf(%q) = line %d (%v), want line %d", tt.path, rule.Line, ok, tt.line)
+		}
+	}
+
+	if _, ok := Parse("CODEOWNERS", "/docs/ @org/docs\n").OwnersOf("main.go"); ok {
+		t.Errorf("OwnersOf(main.go) matched a rule, want none")
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/google/go-github/github"
 	"github.com/sirupsen/logrus"
+	"github.com/srizzling/shepherd/codeowners"
 	"github.com/srizzling/shepherd/shepherd"
 )
 
@@ -15,10 +17,14 @@ var version = "master"
 var (
 	token      string
 	baseURL    string
+	configFile string
 	org        string
 	dryRun     bool
 	maintainer string
 	pbranch    string
+	repoName   string
+
+	policy *shepherd.Policy
 
 	vrsn bool
 )
@@ -44,49 +50,90 @@ developed with <3 by Sriram Venkatesh
 func init() {
 	// parse flags
 	flag.StringVar(&token, "token", os.Getenv("GITHUB_TOKEN"), "required: GitHub API token (or env var GITHUB_TOKEN)")
-	flag.StringVar(&org, "org", "", "required: organization to look through")
-	flag.StringVar(&pbranch, "branch", "master", "branch to protect")
+	flag.StringVar(&configFile, "config", "", "optional: policy file (e.g. shepherd.yaml) describing how repos should be herded")
+	flag.StringVar(&org, "org", "", "required: organization to look through (unless set in the policy file)")
+	flag.StringVar(&pbranch, "branch", "", "optional: branch to protect, overrides the policy file (default: the repo's default branch)")
+	flag.StringVar(&repoName, "repo", "", "optional: only herd this repository")
 
 	flag.StringVar(&baseURL, "url", "", "optional: GitHub Enterprise URL")
-	flag.StringVar(&maintainer, "maintainer", "", "required: team to set as CODEOWNERS")
+	flag.StringVar(&maintainer, "maintainer", "", "required: team to set as CODEOWNERS (unless set in the policy file)")
 	flag.BoolVar(&dryRun, "dryrun", false, "optional: do not change branch settings just print the changes that would occur")
 
 	flag.BoolVar(&vrsn, "version", false, "optional: print version and exit")
 
-	// Exit safely when version is used
-	if vrsn {
-		fmt.Printf(BANNER, version)
-		os.Exit(0)
-	}
-
 	flag.Usage = func() {
 		fmt.Fprint(os.Stderr, fmt.Sprintf(BANNER, version))
 		flag.PrintDefaults()
+		fmt.Fprint(os.Stderr, "\nCommands:\n  rollback <run-id>\n    \trestore the settings changed by a previous run from its snapshot\n")
 	}
 	flag.Parse()
 
+	// Exit safely when version is used
+	if vrsn {
+		fmt.Printf(BANNER, version)
+		os.Exit(0)
+	}
+
 	if token == "" {
 		usageAndExit("GitHub token cannot be empty.", 1)
 	}
 
-	if org == "" {
-		usageAndExit("no organization provided", 1)
+	var err error
+	policy, err = loadPolicy()
+	if err != nil {
+		usageAndExit(err.Error(), 1)
 	}
+}
 
-	if maintainer == "" {
-		usageAndExit("no organization provided", 1)
+// loadPolicy reads the policy file (if provided) and applies the flags that were explicitly set on top of it
+func loadPolicy() (*shepherd.Policy, error) {
+	p := shepherd.NewPolicy("")
+	if configFile != "" {
+		var err error
+		p, err = shepherd.LoadPolicy(configFile)
+		if err != nil {
+			return nil, err
+		}
 	}
 
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "org":
+			p.Org = org
+		case "dryrun":
+			p.DryRun = dryRun
+		case "maintainer":
+			p.SetDefault("maintainer", maintainer)
+		case "branch":
+			p.SetDefault("branch", pbranch)
+		case "repo":
+			p.Select.Repo = repoName
+		}
+	})
+
+	return p, p.Validate()
 }
 
 func main() {
 	// intialize bot
-	bot, err := shepherd.NewBot(baseURL, token, maintainer, org)
+	bot, err := shepherd.NewBot(baseURL, token, policy)
 	if err != nil {
 		logrus.Fatal(err)
 		panic(err)
 	}
 
+	if args := flag.Args(); len(args) > 0 {
+		if args[0] != "rollback" || len(args) != 2 {
+			usageAndExit(fmt.Sprintf("unknown command %q, the only command is: rollback <run-id>", strings.Join(args, " ")), 1)
+		}
+
+		err = rollback(bot, args[1])
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		return
+	}
+
 	//Retreive repos that are owned by the org
 	repos, err := bot.RetreiveRepos()
 	if err != nil {
@@ -101,74 +148,623 @@ func main() {
 			panic(err)
 		}
 	}
+
+	if _, err := os.Stat(bot.SnapshotPath(bot.RunID())); err == nil {
+		fmt.Printf("[INFO] the prior state of the changed settings is in %s, undo this run with: shepherd rollback %s\n", bot.SnapshotPath(bot.RunID()), bot.RunID())
+	}
+}
+
+// rollback restores every setting changed by the run to the state it was in before the run
+func rollback(bot *shepherd.ShepardBot, runID string) error {
+	snapshot, err := bot.LoadSnapshot(runID)
+	if err != nil {
+		return err
+	}
+
+	// settings are restored in the reverse order they were changed
+	for i := len(snapshot.Entries) - 1; i >= 0; i-- {
+		entry := snapshot.Entries[i]
+		fmt.Printf("[UPDATE REQUIRED] %s should be restored\n", entry)
+
+		if !policy.DryRun {
+			err = bot.DoRestore(entry)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("[UPDATED] %s has been restored\n", entry)
+		}
+	}
+
+	return nil
 }
 
 // a function that will be applied to each repo on an org
 func handleRepo(bot *shepherd.ShepardBot, repo *github.Repository) error {
-	b, err := bot.GetBranch(repo, pbranch)
+	settings, err := bot.Settings(repo)
 	if err != nil {
 		return err
 	}
 
-	coExist, prExist, err := bot.CheckCodeOwners(repo, b)
+	if settings.Skip {
+		fmt.Printf("[SKIPPED] %s: repo has opted out of shepherd\n", *repo.FullName)
+		return nil
+	}
+
+	b, err := bot.GetBranch(repo, settings.Branch)
+	if err != nil {
+		return err
+	}
+
+	// the maintainer team is given access first, so it can be asked to review the PRs opened below
+	if settings.RuleEnabled(shepherd.RuleTeam) {
+		err = handleTeam(bot, repo, settings)
+		if err != nil {
+			return err
+		}
+	}
+
+	if settings.RuleEnabled(shepherd.RuleFiles) && settings.Files.Dir != "" {
+		err = handleFiles(bot, repo, b, settings)
+		if err != nil {
+			return err
+		}
+	}
+
+	if settings.RuleEnabled(shepherd.RuleCommunity) && len(settings.Community.Required) > 0 {
+		err = handleCommunity(bot, repo, b, settings)
+		if err != nil {
+			return err
+		}
+	}
+
+	if settings.RuleEnabled(shepherd.RuleCollaborators) && settings.Collaborators.Audit {
+		err = handleCollaborators(bot, repo, settings)
+		if err != nil {
+			return err
+		}
+	}
+
+	if settings.RuleEnabled(shepherd.RuleCollaborators) && settings.Collaborators.Direct.Audit {
+		err = handleDirectGrants(bot, repo, settings)
+		if err != nil {
+			return err
+		}
+	}
+
+	if settings.RuleEnabled(shepherd.RuleCodeOwners) {
+		merged, err := handleCodeOwners(bot, repo, b, settings)
+		if err != nil || !merged {
+			return err // shouldn't go further until the CODEOWNERS file has been merged
+		}
+	}
+
+	if !settings.RuleEnabled(shepherd.RuleProtection) {
+		return nil
+	}
+
+	branches, err := bot.ProtectedBranches(repo)
 	if err != nil {
 		return err
 	}
 
-	if !coExist {
+	for _, pb := range branches {
+		err = handleProtection(bot, repo, pb)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// handleCodeOwners ensures the CODEOWNERS file exists, returns true if it is merged into the branch
+func handleCodeOwners(bot *shepherd.ShepardBot, repo *github.Repository, b *github.Branch, settings *shepherd.Settings) (bool, error) {
+	coExist, prExist, err := bot.CheckCodeOwners(repo, b)
+	if err != nil {
+		return false, err
+	}
+
+	if !coExist && prExist == nil {
 		fmt.Printf("[UPDATE REQUIRED] %s: A codeowner file was not found, a PR should be created\n", *repo.FullName)
 
-		if !dryRun {
+		if !policy.DryRun {
 			pr, err := bot.DoCreateCodeowners(repo, b)
 			if err != nil {
-				return err
+				return false, err
 			}
-			fmt.Printf("[UPDATED] %s: A PR (%s) has been created to add CODEOWNERS file\n", *repo.FullName, pr.GetIssueURL())
+			fmt.Printf("[UPDATED] %s: A PR (%s) has been created to add CODEOWNERS file\n", *repo.FullName, pr.GetHTMLURL())
 		}
 
-		return nil // shouldn't go further at this point, since the PR has to be merged
-	} else if prExist != nil {
-		fmt.Printf("[MERGE REQUIRED] %s: CODEOWNERS file exists in a PR, please merge this before continuing\n", *repo.FullName)
-		return nil // also shoudn't do anything since the PR hasn't been merged yet
+		return false, nil // shouldn't go further at this point, since the PR has to be merged
+	} else if !coExist {
+		fmt.Printf("[MERGE REQUIRED] %s: CODEOWNERS file exists in a PR (%s), please merge this before continuing\n", *repo.FullName, prExist.GetHTMLURL())
+
+		if !policy.DryRun {
+			// keeps the PR up to date with the branch and the policy
+			prExist, err = bot.DoCreateCodeowners(repo, b)
+			if err != nil {
+				return false, err
+			}
+		}
+
+		merged, err := handleAutoMerge(bot, repo, prExist, settings)
+		if err != nil || !merged {
+			return false, err // also shoudn't do anything since the PR hasn't been merged yet
+		}
+
+		// carry on with the CODEOWNERS file that has just been merged
+		b, err = bot.GetBranch(repo, b.GetName())
+		if err != nil {
+			return false, err
+		}
+		return handleCodeOwners(bot, repo, b, settings)
 	}
 	fmt.Printf("[OK] %s: CODEOWNERS file already exists in repo\n", *repo.FullName)
 
+	if prExist != nil {
+		err = closeStalePR(bot, repo, prExist, fmt.Sprintf("a CODEOWNERS file has been added to %s", b.GetName()))
+		if err != nil {
+			return false, err
+		}
+	}
+
+	files, err := bot.CodeOwnersFiles(repo, b)
+	if err != nil {
+		return false, err
+	}
+
+	for _, finding := range shepherd.LintCodeOwners(files) {
+		fmt.Printf("[%s] %s: %s\n", finding.Severity, *repo.FullName, finding)
+	}
+
+	if len(files) > 0 && settings.CodeOwners.ValidateOwners {
+		err = handleCodeOwnersOwners(bot, repo, b, files[0], settings)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if len(files) > 0 && settings.CodeOwners.Coverage {
+		coverage, err := bot.CodeOwnersCoverage(repo, b, files[0])
+		if err != nil {
+			return false, err
+		}
+
+		fmt.Printf("[INFO] %s: %s assigns an owner to %.1f%% of files (%d/%d)\n", *repo.FullName, files[0].Path, coverage.Percent(), coverage.Owned, coverage.Total)
+		if len(coverage.UnownedDirs) > 0 {
+			fmt.Printf("[INFO] %s: directories without an owner: %s\n", *repo.FullName, strings.Join(coverage.UnownedDirs, ", "))
+		}
+	}
+
+	if len(files) > 0 && settings.CodeOwners.EnsureMaintainer {
+		err = handleCodeOwnersMaintainer(bot, repo, b, files[0], settings)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// handleCodeOwnersMaintainer ensures the maintainer team owns every file in the CODEOWNERS file GitHub honours
+func handleCodeOwnersMaintainer(bot *shepherd.ShepardBot, repo *github.Repository, b *github.Branch, file *codeowners.File, settings *shepherd.Settings) error {
+	ok, err := bot.CheckCodeOwnersMaintainer(repo, file)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		fmt.Printf("[OK] %s: %s owns every file in %s\n", *repo.FullName, settings.Maintainer, file.Path)
+		return closeStalePRKind(bot, repo, shepherd.PREnsureCodeOwners, fmt.Sprintf("%s already owns every file", settings.Maintainer))
+	}
+
+	fmt.Printf("[UPDATE REQUIRED] %s: %s is not an owner of every file in %s, a PR should be created\n", *repo.FullName, settings.Maintainer, file.Path)
+
+	if !policy.DryRun {
+		pr, err := bot.DoEnsureCodeOwnersMaintainer(repo, b, file)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("[UPDATED] %s: A PR (%s) has been created to add %s to CODEOWNERS\n", *repo.FullName, pr.GetHTMLURL(), settings.Maintainer)
+
+		_, err = handleAutoMerge(bot, repo, pr, settings)
+		return err
+	}
+
+	return nil
+}
+
+// handleCodeOwnersOwners reports the owners of the CODEOWNERS file GitHub honours that cannot approve changes
+func handleCodeOwnersOwners(bot *shepherd.ShepardBot, repo *github.Repository, b *github.Branch, file *codeowners.File, settings *shepherd.Settings) error {
+	broken, err := bot.CheckCodeOwnersOwners(repo, file)
+	if err != nil {
+		return err
+	}
+
+	for _, owner := range broken {
+		finding := owner.Finding(file.Path)
+		fmt.Printf("[%s] %s: %s\n", finding.Severity, *repo.FullName, finding)
+	}
+
+	if !settings.CodeOwners.FixOwners {
+		return nil
+	}
+
+	// unverifiable owners are only warned about, they may be valid
+	broken = shepherd.FixableOwners(broken)
+	if len(broken) == 0 {
+		return closeStalePRKind(bot, repo, shepherd.PRFixCodeOwnersOwners, "every CODEOWNERS owner can now approve changes")
+	}
+
+	fmt.Printf("[UPDATE REQUIRED] %s: %d CODEOWNERS owners cannot approve changes, a PR should be created\n", *repo.FullName, len(broken))
+
+	if !policy.DryRun {
+		pr, err := bot.DoFixCodeOwnersOwners(repo, b, file, broken)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("[UPDATED] %s: A PR (%s) has been created to fix the CODEOWNERS owners\n", *repo.FullName, pr.GetHTMLURL())
+
+		_, err = handleAutoMerge(bot, repo, pr, settings)
+		return err
+	}
+
+	return nil
+}
+
+// handleAutoMerge merges a shepherd PR once it is approved and green, otherwise the maintainers are nagged
+// about it once it is old enough. Returns true if the PR has been merged
+func handleAutoMerge(bot *shepherd.ShepardBot, repo *github.Repository, pr *github.PullRequest, settings *shepherd.Settings) (bool, error) {
+	if !settings.PullRequests.AutoMerge.Enabled {
+		return false, nil
+	}
+
+	pr, mergeable, reason, err := bot.CheckPRMergeable(repo, pr)
+	if err != nil {
+		return false, err
+	}
+
+	if mergeable {
+		fmt.Printf("[UPDATE REQUIRED] %s: PR (%s) is approved and green, it should be merged\n", *repo.FullName, pr.GetHTMLURL())
+
+		if policy.DryRun {
+			return false, nil
+		}
+
+		err = bot.DoMergePR(repo, pr)
+		if err != nil {
+			return false, err
+		}
+		fmt.Printf("[UPDATED] %s: PR (%s) has been merged\n", *repo.FullName, pr.GetHTMLURL())
+		return true, nil
+	}
+
+	fmt.Printf("[INFO] %s: PR (%s) cannot be merged yet as %s\n", *repo.FullName, pr.GetHTMLURL(), reason)
+
+	nag, err := bot.CheckPRNag(repo, pr)
+	if err != nil || !nag {
+		return false, err
+	}
+
+	fmt.Printf("[UPDATE REQUIRED] %s: PR (%s) has been open for too long, %s should be reminded\n", *repo.FullName, pr.GetHTMLURL(), settings.Maintainer)
+
+	if !policy.DryRun {
+		err = bot.DoNagPR(repo, pr, reason)
+		if err != nil {
+			return false, err
+		}
+		fmt.Printf("[UPDATED] %s: PR (%s) has been commented on\n", *repo.FullName, pr.GetHTMLURL())
+	}
+
+	return false, nil
+}
+
+// closeStalePRKind closes shepherd's open PR of the kind (if any), as it is no longer needed
+func closeStalePRKind(bot *shepherd.ShepardBot, repo *github.Repository, kind string, reason string) error {
+	pr, err := bot.FindPR(repo, kind)
+	if err != nil || pr == nil {
+		return err
+	}
+
+	return closeStalePR(bot, repo, pr, reason)
+}
+
+// closeStalePR closes a shepherd PR that is no longer needed and deletes its branch
+func closeStalePR(bot *shepherd.ShepardBot, repo *github.Repository, pr *github.PullRequest, reason string) error {
+	fmt.Printf("[UPDATE REQUIRED] %s: PR (%s) is no longer needed as %s, it should be closed\n", *repo.FullName, pr.GetHTMLURL(), reason)
+
+	if !policy.DryRun {
+		err := bot.DoClosePR(repo, pr, reason)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("[UPDATED] %s: PR (%s) has been closed\n", *repo.FullName, pr.GetHTMLURL())
+	}
+
+	return nil
+}
+
+// handleFiles ensures the files of the template directory are in sync with the branch
+func handleFiles(bot *shepherd.ShepardBot, repo *github.Repository, b *github.Branch, settings *shepherd.Settings) error {
+	outdated, err := bot.CheckManagedFiles(repo, b)
+	if err != nil {
+		return err
+	}
+
+	if len(outdated) == 0 {
+		fmt.Printf("[OK] %s: managed files are in sync\n", *repo.FullName)
+		return closeStalePRKind(bot, repo, shepherd.PRSyncFiles, "the managed files are in sync")
+	}
+
+	for _, f := range outdated {
+		if f.Block {
+			fmt.Printf("[UPDATE REQUIRED] %s: managed block of %s is out of date\n", *repo.FullName, f.Path)
+		} else if f.Exists {
+			fmt.Printf("[UPDATE REQUIRED] %s: managed file %s is out of date\n", *repo.FullName, f.Path)
+		} else {
+			fmt.Printf("[UPDATE REQUIRED] %s: managed file %s is missing\n", *repo.FullName, f.Path)
+		}
+	}
+
+	if !policy.DryRun {
+		pr, err := bot.DoSyncManagedFiles(repo, b, outdated)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("[UPDATED] %s: A PR (%s) has been created to sync %d managed files\n", *repo.FullName, pr.GetHTMLURL(), len(outdated))
+
+		_, err = handleAutoMerge(bot, repo, pr, settings)
+		return err
+	}
+
+	return nil
+}
+
+// handleCommunity ensures the community profile of the repo has the required items
+func handleCommunity(bot *shepherd.ShepardBot, repo *github.Repository, b *github.Branch, settings *shepherd.Settings) error {
+	// the community profile only reflects the default branch, so the files are proposed to it
+	if b.GetName() != repo.GetDefaultBranch() {
+		var err error
+		b, err = bot.GetBranch(repo, repo.GetDefaultBranch())
+		if err != nil {
+			return err
+		}
+	}
+
+	health, err := bot.CheckCommunityHealth(repo, b)
+	if err == shepherd.ErrNoCommunityProfile {
+		fmt.Printf("[SKIPPED] %s: %v\n", *repo.FullName, err)
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[INFO] %s: community profile is %d%% complete\n", *repo.FullName, health.Percentage)
+
+	if len(health.Missing) == 0 {
+		fmt.Printf("[OK] %s: community profile has every required item\n", *repo.FullName)
+		return closeStalePRKind(bot, repo, shepherd.PRCommunity, "the community profile has every required item")
+	}
+
+	fmt.Printf("[UPDATE REQUIRED] %s: community profile is missing %s\n", *repo.FullName, strings.Join(health.Missing, ", "))
+
+	if len(health.NoTemplate) > 0 {
+		fmt.Printf("[WARN] %s: no template for %s, these have to be added by hand\n", *repo.FullName, strings.Join(health.NoTemplate, ", "))
+	}
+
+	if len(health.Files) == 0 {
+		return nil
+	}
+
+	if !policy.DryRun {
+		pr, err := bot.DoCommunityHealth(repo, b, health)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("[UPDATED] %s: A PR (%s) has been created to add %d community health files\n", *repo.FullName, pr.GetHTMLURL(), len(health.Files))
+
+		_, err = handleAutoMerge(bot, repo, pr, settings)
+		return err
+	}
+
+	return nil
+}
+
+// handleCollaborators reports the outside collaborators of the repo, removing the flagged ones if the policy
+// asks for it
+func handleCollaborators(bot *shepherd.ShepardBot, repo *github.Repository, settings *shepherd.Settings) error {
+	collaborators, err := bot.CheckOutsideCollaborators(repo)
+	if err != nil {
+		return err
+	}
+
+	var flagged []shepherd.Collaborator
+	for _, c := range collaborators {
+		if !c.Flagged {
+			fmt.Printf("[OK] %s: outside collaborator %s (%s) is allowed\n", *repo.FullName, c.Login, c.Permission)
+			continue
+		}
+
+		flagged = append(flagged, c)
+		if settings.Collaborators.Remove {
+			fmt.Printf("[UPDATE REQUIRED] %s: outside collaborator %s (%s) should be removed, %s\n", *repo.FullName, c.Login, c.Permission, c.Reason)
+		} else {
+			fmt.Printf("[WARN] %s: outside collaborator %s (%s), %s\n", *repo.FullName, c.Login, c.Permission, c.Reason)
+		}
+	}
+
+	if len(collaborators) == 0 {
+		fmt.Printf("[OK] %s: has no outside collaborators\n", *repo.FullName)
+	}
+
+	if !policy.DryRun && settings.Collaborators.Remove && len(flagged) > 0 {
+		err = bot.DoRemoveCollaborators(repo, flagged)
+		if err != nil {
+			return err
+		}
+
+		for _, c := range flagged {
+			fmt.Printf("[UPDATED] %s: outside collaborator %s (%s) has been removed\n", *repo.FullName, c.Login, c.Permission)
+		}
+	}
+
+	return nil
+}
+
+// handleDirectGrants reports the org members with direct access to the repo, a direct grant that gives more
+// access than the teams of the member bypasses the team model. In fix mode grants that are covered by a team
+// are removed
+func handleDirectGrants(bot *shepherd.ShepardBot, repo *github.Repository, settings *shepherd.Settings) error {
+	grants, err := bot.CheckDirectGrants(repo)
+	if err != nil {
+		return err
+	}
+
+	if len(grants) == 0 {
+		fmt.Printf("[OK] %s: no members have been granted access directly\n", *repo.FullName)
+		return nil
+	}
+
+	var covered []shepherd.DirectGrant
+	for _, g := range grants {
+		teamPermission := g.TeamPermission
+		if teamPermission == "" {
+			teamPermission = "none"
+		}
+
+		switch {
+		case g.Bypasses():
+			fmt.Printf("[WARN] %s: member %s has been granted %s directly but only has %s through its teams, grant the access through a team\n", *repo.FullName, g.Login, g.Permission, teamPermission)
+		case !g.Covered():
+			fmt.Printf("[INFO] %s: direct grant of member %s is covered by the base permission of the org (%s) but not by its teams (%s)\n", *repo.FullName, g.Login, g.BasePermission, teamPermission)
+		case settings.Collaborators.Direct.Fix:
+			covered = append(covered, g)
+			fmt.Printf("[UPDATE REQUIRED] %s: direct grant of member %s is covered by its teams (%s) and should be removed\n", *repo.FullName, g.Login, teamPermission)
+		default:
+			fmt.Printf("[INFO] %s: direct grant of member %s is covered by its teams (%s)\n", *repo.FullName, g.Login, teamPermission)
+		}
+	}
+
+	if !policy.DryRun && len(covered) > 0 {
+		removed, err := bot.DoRemoveDirectGrants(repo, covered)
+		for _, g := range removed {
+			fmt.Printf("[UPDATED] %s: direct grant of member %s has been removed, %s is now granted through its teams\n", *repo.FullName, g.Login, g.TeamPermission)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// handleTeam ensures the maintainer team manages the repo
+func handleTeam(bot *shepherd.ShepardBot, repo *github.Repository, settings *shepherd.Settings) error {
 	//Need to assign team to the repo even its in the org to be a "maintainer"
-	repoManagement, err := bot.CheckTeamRepoManagement(repo)
+	repoManagement, permission, err := bot.CheckTeamRepoManagement(repo)
 
 	if err != nil {
 		return err
 	}
 
 	if repoManagement {
-		fmt.Printf("[OK] %s: is already managed by %s\n", *repo.FullName, maintainer)
-	} else {
-		fmt.Printf("[UPDATE REQUIRED] %s: needs to updated to be managed by %s\n", *repo.FullName, maintainer)
+		fmt.Printf("[OK] %s: is already managed by %s (%s)\n", *repo.FullName, settings.Maintainer, permission)
+		return handleTeamAccess(bot, repo, settings)
+	}
 
-		if !dryRun {
-			err = bot.DoTeamRepoManagement(repo)
-			if err != nil {
-				return err
+	if permission == "" {
+		permission = "none"
+	}
+	fmt.Printf("[UPDATE REQUIRED] %s: needs to updated to be managed by %s (%s → %s)\n", *repo.FullName, settings.Maintainer, permission, settings.MaintainerPermission)
+
+	if !policy.DryRun {
+		err = bot.DoTeamRepoManagement(repo)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("[OK] %s: is now managed by %s (%s)\n", *repo.FullName, settings.Maintainer, settings.MaintainerPermission)
+	}
+
+	return handleTeamAccess(bot, repo, settings)
+}
+
+// handleTeamAccess ensures every team has the access the policy grants it, revoking the access of teams
+// without a grant in authoritative mode
+func handleTeamAccess(bot *shepherd.ShepardBot, repo *github.Repository, settings *shepherd.Settings) error {
+	if len(settings.Teams.Grants) == 0 && !settings.Teams.Authoritative {
+		return nil
+	}
+
+	changes, err := bot.CheckTeamAccess(repo)
+	if err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		fmt.Printf("[OK] %s: every team has the access granted by the policy\n", *repo.FullName)
+		return nil
+	}
+
+	for _, change := range changes {
+		switch {
+		case change.Desired == "":
+			fmt.Printf("[UPDATE REQUIRED] %s: team %s (%s) is not granted access by the policy, access should be revoked\n", *repo.FullName, change.Team, change.Current)
+		case change.Current == "":
+			fmt.Printf("[UPDATE REQUIRED] %s: team %s should be granted %s\n", *repo.FullName, change.Team, change.Desired)
+		default:
+			fmt.Printf("[UPDATE REQUIRED] %s: team %s should have %s rather than %s\n", *repo.FullName, change.Team, change.Desired, change.Current)
+		}
+	}
+
+	if !policy.DryRun {
+		err = bot.DoTeamAccess(repo, changes)
+		if err != nil {
+			return err
+		}
+
+		for _, change := range changes {
+			if change.Desired == "" {
+				fmt.Printf("[UPDATED] %s: access of team %s (%s) has been revoked\n", *repo.FullName, change.Team, change.Current)
+			} else {
+				fmt.Printf("[UPDATED] %s: team %s now has %s\n", *repo.FullName, change.Team, change.Desired)
 			}
-			fmt.Printf("[OK] %s: is now managed by %s\n", *repo.FullName, maintainer)
 		}
 	}
 
+	return nil
+}
+
+// handleProtection ensures the branch is protected
+func handleProtection(bot *shepherd.ShepardBot, repo *github.Repository, b *github.Branch) error {
 	// BRANCH PROTECTION + REQUIRED STATUS CHECKS
-	branchProtect, err := bot.CheckProtectionBranch(repo, b)
+	branchProtect, changes, err := bot.CheckProtectionBranch(repo, b)
+	if err != nil {
+		return err
+	}
+
+	proposed, err := bot.ProposedStatusChecks(repo, b)
 	if err != nil {
 		return err
 	}
 
+	if len(proposed) > 0 {
+		fmt.Printf("[INFO] %s: %s status checks %s passed on the latest commits, they can be required with protection.status_checks.contexts\n", *repo.FullName, b.GetName(), strings.Join(proposed, ", "))
+	}
+
 	if branchProtect {
 		fmt.Printf("[OK] %s: %s is already protected\n", *repo.FullName, b.GetName())
 		return nil
 	}
 
 	fmt.Printf("[UPDATE REQUIRED] %s: %s requires branch protection\n", *repo.FullName, b.GetName())
+	for _, change := range changes {
+		fmt.Printf("[UPDATE REQUIRED] %s: %s %s\n", *repo.FullName, b.GetName(), change)
+	}
 
 	// protect branch above
-	if !dryRun {
+	if !policy.DryRun {
 		err = bot.DoProtectBranch(repo, b)
 		if err != nil {
 			return err
@@ -181,7 +777,7 @@ func handleRepo(bot *shepherd.ShepardBot, repo *github.Repository) error {
 
 func usageAndExit(message string, exitCode int) {
 	if message != "" {
-		fmt.Fprintf(os.Stderr, message)
+		fmt.Fprint(os.Stderr, message)
 		fmt.Fprintf(os.Stderr, "\n\n")
 	}
 	flag.Usage()
@@ -0,0 +1,122 @@
+package shepherd
+
+import (
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/google/go-github/github"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// DefaultBranchPattern matches the default branch of the repo, whatever it is called
+const DefaultBranchPattern = "@default"
+
+// BranchSettings protects every branch of the repo matching the pattern, the protection is layered on top
+// of the protection settings of the repo
+type BranchSettings struct {
+	// Pattern is a branch name, a glob (e.g. release/*) or @default
+	Pattern    string                 `yaml:"pattern"`
+	Protection map[string]interface{} `yaml:"protection"`
+}
+
+func (b *BranchSettings) validate(base ProtectionSettings) error {
+	if b.Pattern == "" {
+		return errors.New("branches require a pattern")
+	}
+
+	if _, err := path.Match(b.Pattern, ""); err != nil {
+		return fmt.Errorf("branches: invalid pattern %q: %v", b.Pattern, err)
+	}
+
+	protection, err := b.protection(base)
+	if err != nil {
+		return fmt.Errorf("branches: %s: %v", b.Pattern, err)
+	}
+	return protection.validate()
+}
+
+// matches returns true if the branch name matches the pattern
+func (b *BranchSettings) matches(repo *github.Repository, name string) bool {
+	if b.Pattern == DefaultBranchPattern {
+		return name == repo.GetDefaultBranch()
+	}
+
+	matched, _ := path.Match(b.Pattern, name)
+	return matched
+}
+
+// protection returns the base protection with the protection of the branch layered on top
+func (b *BranchSettings) protection(base ProtectionSettings) (ProtectionSettings, error) {
+	if len(b.Protection) == 0 {
+		return base, nil
+	}
+
+	data, err := yaml.Marshal(b.Protection)
+	if err != nil {
+		return base, err
+	}
+
+	err = yaml.UnmarshalStrict(data, &base)
+	return base, err
+}
+
+// branchProtection returns the protection the branch requires, the protection of the first branches
+// pattern matching the branch is used. Branches that match no pattern have the protection of the repo
+func (s *ShepardBot) branchProtection(repo *github.Repository, name string) (ProtectionSettings, error) {
+	settings, err := s.Settings(repo)
+	if err != nil {
+		return ProtectionSettings{}, err
+	}
+
+	for _, b := range settings.Branches {
+		if b.matches(repo, name) {
+			return b.protection(settings.Protection)
+		}
+	}
+
+	return settings.Protection, nil
+}
+
+// ProtectedBranches returns every branch of the repo that has to be protected. Without any branches
+// patterns that is only the branch of the repo
+func (s *ShepardBot) ProtectedBranches(repo *github.Repository) ([]*github.Branch, error) {
+	settings, err := s.Settings(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(settings.Branches) == 0 {
+		branch, err := s.GetBranch(repo, settings.Branch)
+		if err != nil {
+			return nil, err
+		}
+		return []*github.Branch{branch}, nil
+	}
+
+	opt := &github.ListOptions{PerPage: 100}
+	var protected []*github.Branch
+
+	for {
+		branches, resp, err := s.gClient.Repositories.ListBranches(s.ctx, *repo.Owner.Login, *repo.Name, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, b := range branches {
+			for _, pattern := range settings.Branches {
+				if pattern.matches(repo, b.GetName()) {
+					protected = append(protected, b)
+					break
+				}
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return protected, nil
+}
@@ -2,133 +2,213 @@ package shepherd
 
 import (
 	"fmt"
-	"net/http"
+	"path"
 
-	"github.com/adam-hanna/randomstrings"
 	"github.com/google/go-github/github"
+	"github.com/sirupsen/logrus"
+	"github.com/srizzling/shepherd/codeowners"
 )
 
-func (s *ShepardBot) createBranch(repo *github.Repository, refObj *github.Reference) error {
-	_, resp, err := s.gClient.Git.CreateRef(s.ctx, *repo.Owner.Login, *repo.Name, refObj)
+// DoCreateCodeowners function will create a CODEOWNERS file in a branch, create a PR against the repo
+// and request a review (of the CODEOWNERS PR) from the maintainer team configured
+func (s *ShepardBot) DoCreateCodeowners(repo *github.Repository, branch *github.Branch) (*github.PullRequest, error) {
+	settings, err := s.Settings(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := s.renderCodeOwners(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var suggestion string
+	if settings.CodeOwners.Suggest.Enabled {
+		rules, err := s.SuggestCodeOwners(repo, branch)
+		if err != nil {
+			return nil, err
+		}
 
-	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusForbidden {
-		return &ShepardError{resp: resp}
+		if len(rules) > 0 {
+			suggestion = formatSuggestion(rules)
+		}
 	}
 
-	return err
+	return s.proposeChange(repo, branch, &fileChange{
+		kind:    PRAddCodeOwners,
+		files:   []changedFile{{path: settings.CodeOwners.Path, content: content}},
+		details: suggestion,
+	})
 }
 
-func (s *ShepardBot) commitFileToBranch(repo *github.Repository, branchName string) error {
-	content := []byte(
-		fmt.Sprintf("* @%s/%s", s.org.GetLogin(), s.maintainerTeam.GetName()),
-	)
-
-	_, _, err := s.gClient.Repositories.CreateFile(
-		s.ctx,
-		*repo.Owner.Login,
-		*repo.Name,
-		".github/CODEOWNERS",
-		&github.RepositoryContentFileOptions{
-			Branch:  github.String(branchName),
-			Message: github.String("Adding CODEOWNERS file"),
-			Content: content,
-		},
-	)
-
-	return err
+// CheckCodeOwners verifies if the CODEOWNERS file exist in the repo, in the specfied branch. It also
+// returns shepherd's open PR adding the CODEOWNERS file (if any)
+func (s *ShepardBot) CheckCodeOwners(repo *github.Repository, branch *github.Branch) (bool, *github.PullRequest, error) {
+	pr, err := s.FindPR(repo, PRAddCodeOwners)
+	if err != nil {
+		return false, nil, err
+	}
+
+	// CODEOWNERS can be in .github or docs or in the root of the repo
+	for _, coPath := range codeowners.Locations {
+		_, found, err := s.getFile(repo, coPath, branch.GetName())
+		if err != nil {
+			return false, nil, err
+		}
+
+		if found {
+			return true, pr, nil
+		}
+	}
+
+	// Looked everywhere the codeowners file couldn't be found
+	return false, pr, nil
 }
 
-func (s *ShepardBot) createPR(repo *github.Repository, branchName string, branch *github.Branch) (*github.PullRequest, error) {
-	prMessage := fmt.Sprintf("Hi there @%s!,\n\nI'm your helpful shepherd and I've found that you are missing an important CODEOWNERS file which is mandated to be included for repos within this org (this ensures that the maintainers are pinged to review PR as they come in).\n\nThis PR is automatically created by [shepherd](https://github.com/srizzling/shepherd)\n\nThanks,\nShepard Bot", s.maintainerTeam.GetName())
+// CodeOwnersFiles returns the parsed CODEOWNERS files in the branch, in the order GitHub honours them
+func (s *ShepardBot) CodeOwnersFiles(repo *github.Repository, branch *github.Branch) ([]*codeowners.File, error) {
+	var files []*codeowners.File
+
+	for _, coPath := range codeowners.Locations {
+		content, found, err := s.getFile(repo, coPath, branch.GetName())
+		if err != nil {
+			return nil, err
+		}
 
-	// Create a PR with the branch created
-	newPR := &github.NewPullRequest{
-		Title:               github.String("[AUTOMATED] Adding CODEOWNERS file"),
-		MaintainerCanModify: github.Bool(true),
-		Head:                github.String(branchName),
-		Base:                github.String(branch.GetName()),
-		Body:                github.String(prMessage),
+		if found {
+			files = append(files, codeowners.Parse(coPath, content))
+		}
 	}
 
-	pr, _, err := s.gClient.PullRequests.Create(s.ctx, *repo.Owner.Login, *repo.Name, newPR)
-	return pr, err
+	return files, nil
 }
 
-// DoCreateCodeowners function will create a CODEOWNERS file in a branch, create a PR against the repo
-// and set the reviewer (of the CODEOWNERS PR) as the maintainer team configured
-func (s *ShepardBot) DoCreateCodeowners(repo *github.Repository, branch *github.Branch) (*github.PullRequest, error) {
-	// Create a branch on the repo, from current master
-	sRand, err := randomstrings.GenerateRandomString(5)
+// LintCodeOwners returns the problems found in the CODEOWNERS files of a branch
+func LintCodeOwners(files []*codeowners.File) []codeowners.Finding {
+	var paths []string
+	var findings []codeowners.Finding
+	for _, f := range files {
+		paths = append(paths, f.Path)
+		findings = append(findings, codeowners.Lint(f)...)
+	}
+
+	return append(findings, codeowners.LintLocations(paths)...)
+}
+
+// CheckCodeOwnersMaintainer verifies the maintainer team is an owner of the catch-all (*) rule of the CODEOWNERS file
+func (s *ShepardBot) CheckCodeOwnersMaintainer(repo *github.Repository, file *codeowners.File) (bool, error) {
+	settings, err := s.Settings(repo)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
 
-	branchName := fmt.Sprintf("add-codeowners-shepherd-%s", sRand)
-	newRef := github.Reference{
-		Ref: github.String(fmt.Sprintf("refs/heads/%s", branchName)),
-		Object: &github.GitObject{
-			SHA: branch.Commit.SHA,
-		},
+	maintainer, err := s.ownerRef(settings.Maintainer)
+	if err != nil {
+		return false, err
 	}
 
-	err = s.createBranch(repo, &newRef)
+	// work on a copy, so the file is left as is
+	return !file.Clone().EnsureCatchAll(maintainer), nil
+}
+
+// DoEnsureCodeOwnersMaintainer opens a PR which adds the maintainer team to the catch-all (*) rule of the
+// existing CODEOWNERS file (or adds the rule), every other rule and comment is preserved
+func (s *ShepardBot) DoEnsureCodeOwnersMaintainer(repo *github.Repository, branch *github.Branch, file *codeowners.File) (*github.PullRequest, error) {
+	settings, err := s.Settings(repo)
 	if err != nil {
 		return nil, err
 	}
 
-	// Commit CODEOWNERS file to Branch
-	err = s.commitFileToBranch(repo, branchName)
+	maintainer, err := s.ownerRef(settings.Maintainer)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create PR with newly created branch
-	pr, err := s.createPR(repo, branchName, branch)
+	file = file.Clone()
+	if !file.EnsureCatchAll(maintainer) {
+		return nil, nil
+	}
+
+	return s.proposeChange(repo, branch, &fileChange{
+		kind:  PREnsureCodeOwners,
+		files: []changedFile{{path: file.Path, content: file.Bytes()}},
+	})
+}
+
+// gitTree is a tree as returned by the API, the tree of the github client does not tell whether the
+// entries have been truncated
+type gitTree struct {
+	Entries   []github.TreeEntry `json:"tree"`
+	Truncated bool               `json:"truncated"`
+}
+
+// getTree returns the tree, recursive returns the entries of every subtree too
+func (s *ShepardBot) getTree(repo *github.Repository, sha string, recursive bool) (*gitTree, error) {
+	u := fmt.Sprintf("repos/%v/%v/git/trees/%v", *repo.Owner.Login, *repo.Name, sha)
+	if recursive {
+		u += "?recursive=1"
+	}
+
+	req, err := s.gClient.NewRequest("GET", u, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	return pr, nil
+	tree := &gitTree{}
+	_, err = s.gClient.Do(s.ctx, req, tree)
+	return tree, err
 }
 
-// CheckCodeOwners verifies if the CODEOWNERS file exist in the repo, in the specfied branch
-func (s *ShepardBot) CheckCodeOwners(repo *github.Repository, branch *github.Branch) (bool, *github.PullRequest, error) {
-	// CODEOWNERS can be in .github or docs or in the root of the repo
-	defaultCodeOwnersLoc := []string{"CODEOWNERS", ".github/CODEOWNERS", "docs/CODEOWNERS"}
+// CodeOwnersCoverage evaluates every file in the branch against the CODEOWNERS file
+func (s *ShepardBot) CodeOwnersCoverage(repo *github.Repository, branch *github.Branch, file *codeowners.File) (*codeowners.Coverage, error) {
+	tree, err := s.getTree(repo, branch.Commit.GetSHA(), true)
+	if err != nil {
+		return nil, err
+	}
 
-	refName := github.String(fmt.Sprintf("refs/heads/%s", branch.GetName()))
+	// the recursive tree of large repos is cut off, the tree is walked one directory at a time instead
+	if tree.Truncated {
+		paths, err := s.walkTree(repo, branch.Commit.GetSHA(), "")
+		if err != nil {
+			return nil, err
+		}
+		return codeowners.CoverageOf(file, paths), nil
+	}
 
-	opt := &github.RepositoryContentGetOptions{
-		Ref: *refName,
+	var paths []string
+	for _, entry := range tree.Entries {
+		if entry.GetType() == "blob" {
+			paths = append(paths, entry.GetPath())
+		}
 	}
 
-	for _, coPath := range defaultCodeOwnersLoc {
-		_, _, resp, err := s.gClient.Repositories.GetContents(
-			s.ctx,
-			*repo.Owner.Login,
-			*repo.Name,
-			coPath,
-			opt,
-		)
+	return codeowners.CoverageOf(file, paths), nil
+}
 
-		if resp.StatusCode != http.StatusNotFound {
-			return true, nil, nil // file not found
-		} else if resp.StatusCode == http.StatusNotFound {
-			continue // file not found skip to next iteration
-		} else if err != nil {
-			return false, nil, err
-		}
+// walkTree returns the path of every file in the tree, fetching the tree of every directory separately
+func (s *ShepardBot) walkTree(repo *github.Repository, sha string, prefix string) ([]string, error) {
+	tree, err := s.getTree(repo, sha, false)
+	if err != nil {
+		return nil, err
 	}
 
-	// Check if there is a PR with the title [AUTOMATED] Adding CODEOWNERS file
-	pulls, _, _ := s.gClient.PullRequests.List(s.ctx, *repo.Owner.Login, *repo.Name, nil)
+	if tree.Truncated {
+		logrus.Warnf("%s: the tree of %q is truncated, the CODEOWNERS coverage is incomplete", repo.GetFullName(), prefix)
+	}
 
-	for _, pr := range pulls {
-		if *pr.Title == "[AUTOMATED] Adding CODEOWNERS file" {
-			return true, pr, nil
+	var paths []string
+	for _, entry := range tree.Entries {
+		switch entry.GetType() {
+		case "blob":
+			paths = append(paths, path.Join(prefix, entry.GetPath()))
+		case "tree":
+			sub, err := s.walkTree(repo, entry.GetSHA(), path.Join(prefix, entry.GetPath()))
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, sub...)
 		}
 	}
 
-	// Looked everywhere the codeowners file couldn't be found
-	return false, nil, nil
+	return paths, nil
 }
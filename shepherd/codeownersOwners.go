@@ -0,0 +1,220 @@
+package shepherd
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/sirupsen/logrus"
+	"github.com/srizzling/shepherd/codeowners"
+)
+
+// BrokenOwner is an owner referenced in a CODEOWNERS file that does not resolve, or does not have
+// the write access required for their review to count
+type BrokenOwner struct {
+	Owner  string
+	Reason string
+	Lines  []int
+	// Unverifiable owners could not be checked, e.g. emails that are not public. They may well be valid,
+	// so they are only warned about and never removed
+	Unverifiable bool
+}
+
+// Finding returns the broken owner as a CODEOWNERS finding
+func (b BrokenOwner) Finding(path string) codeowners.Finding {
+	var lines []string
+	for _, line := range b.Lines {
+		lines = append(lines, fmt.Sprint(line))
+	}
+
+	severity := codeowners.Error
+	if b.Unverifiable {
+		severity = codeowners.Warning
+	}
+
+	return codeowners.Finding{
+		Path:     path,
+		Severity: severity,
+		Message:  fmt.Sprintf("owner %s (line %s) %s", b.Owner, strings.Join(lines, ", "), b.Reason),
+	}
+}
+
+// FixableOwners returns the broken owners that can be removed from the CODEOWNERS file
+func FixableOwners(broken []BrokenOwner) []BrokenOwner {
+	var fixable []BrokenOwner
+	for _, b := range broken {
+		if !b.Unverifiable {
+			fixable = append(fixable, b)
+		}
+	}
+	return fixable
+}
+
+// CheckCodeOwnersOwners verifies every owner of the CODEOWNERS file resolves and has at least push access to the repo
+func (s *ShepardBot) CheckCodeOwnersOwners(repo *github.Repository, file *codeowners.File) ([]BrokenOwner, error) {
+	var broken []BrokenOwner
+	checked := map[string]int{}
+
+	for _, rule := range file.Rules {
+		for _, owner := range rule.Owners {
+			if idx, ok := checked[owner]; ok {
+				if idx >= 0 {
+					broken[idx].Lines = append(broken[idx].Lines, rule.Line)
+				}
+				continue
+			}
+
+			reason, unverifiable, err := s.checkOwner(repo, owner)
+			if err != nil {
+				return nil, err
+			}
+
+			if reason == "" {
+				checked[owner] = -1
+				continue
+			}
+
+			checked[owner] = len(broken)
+			broken = append(broken, BrokenOwner{Owner: owner, Reason: reason, Lines: []int{rule.Line}, Unverifiable: unverifiable})
+		}
+	}
+
+	return broken, nil
+}
+
+// checkOwner returns why the owner cannot approve changes to the repo, or an empty string if they can.
+// Unverifiable is true if the owner could not be checked at all
+func (s *ShepardBot) checkOwner(repo *github.Repository, owner string) (reason string, unverifiable bool, err error) {
+	switch {
+	case codeowners.IsTeam(owner):
+		reason, err = s.checkTeamOwner(repo, owner)
+		return reason, false, err
+	case codeowners.IsUser(owner):
+		reason, err = s.checkUserOwner(repo, strings.TrimPrefix(owner, "@"))
+		return reason, false, err
+	default:
+		// GitHub matches emails against the verified emails of users, which are usually private, so
+		// an email that isn't found is not necessarily broken
+		login, err := s.userByEmail(owner)
+		if err != nil {
+			logrus.Warnf("unable to look up the CODEOWNERS email %s: %v", owner, err)
+			return "could not be verified", true, nil
+		}
+
+		if login == "" {
+			return "could not be verified, it does not match a GitHub user with a public email", true, nil
+		}
+
+		reason, err = s.checkUserOwner(repo, login)
+		return reason, false, err
+	}
+}
+
+func (s *ShepardBot) checkTeamOwner(repo *github.Repository, owner string) (string, error) {
+	parts := strings.SplitN(strings.TrimPrefix(owner, "@"), "/", 2)
+	if !strings.EqualFold(parts[0], s.org.GetLogin()) {
+		return fmt.Sprintf("is not a team of %s", s.org.GetLogin()), nil
+	}
+
+	team, err := s.findTeam(parts[1])
+	if err != nil {
+		return "is not an existing team", nil
+	}
+
+	teamRepo, resp, err := s.gClient.Organizations.IsTeamRepo(s.ctx, team.GetID(), *repo.Owner.Login, *repo.Name)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return "has no access to the repo", nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if !teamRepo.GetPermissions()["push"] {
+		return "does not have write access to the repo", nil
+	}
+
+	return "", nil
+}
+
+func (s *ShepardBot) checkUserOwner(repo *github.Repository, login string) (string, error) {
+	level, resp, err := s.gClient.Repositories.GetPermissionLevel(s.ctx, *repo.Owner.Login, *repo.Name, login)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return "is not an existing user", nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	switch level.GetPermission() {
+	case "admin", "write":
+		return "", nil
+	default:
+		return "does not have write access to the repo", nil
+	}
+}
+
+// userByEmail returns the login of the user with the public email, or an empty string if there is none
+func (s *ShepardBot) userByEmail(email string) (string, error) {
+	result, _, err := s.gClient.Search.Users(s.ctx, fmt.Sprintf("%s in:email", email), nil)
+	if err != nil {
+		return "", err
+	}
+
+	if len(result.Users) != 1 {
+		return "", nil
+	}
+
+	return result.Users[0].GetLogin(), nil
+}
+
+// DoFixCodeOwnersOwners opens a PR which removes the broken owners from the CODEOWNERS file, rules that
+// are left without an owner are assigned to the maintainer team
+func (s *ShepardBot) DoFixCodeOwnersOwners(repo *github.Repository, branch *github.Branch, file *codeowners.File, broken []BrokenOwner) (*github.PullRequest, error) {
+	settings, err := s.Settings(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	maintainer, err := s.ownerRef(settings.Maintainer)
+	if err != nil {
+		return nil, err
+	}
+
+	file = file.Clone()
+	remove := map[string]bool{}
+	var summary []string
+	for _, b := range broken {
+		if b.Unverifiable {
+			continue
+		}
+		remove[b.Owner] = true
+		summary = append(summary, fmt.Sprintf("- `%s` %s", b.Owner, b.Reason))
+	}
+
+	for _, rule := range file.Rules {
+		var owners []string
+		for _, owner := range rule.Owners {
+			if !remove[owner] {
+				owners = append(owners, owner)
+			}
+		}
+
+		if len(owners) == len(rule.Owners) {
+			continue
+		}
+
+		if len(owners) == 0 {
+			owners = []string{maintainer}
+		}
+		file.SetOwners(rule.Line, owners)
+	}
+
+	return s.proposeChange(repo, branch, &fileChange{
+		kind:    PRFixCodeOwnersOwners,
+		files:   []changedFile{{path: file.Path, content: file.Bytes()}},
+		details: strings.Join(summary, "\n"),
+	})
+}
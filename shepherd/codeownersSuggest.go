@@ -0,0 +1,187 @@
+package shepherd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// SuggestSettings configures the CODEOWNERS suggestions made from the commit history of a repo
+type SuggestSettings struct {
+	Enabled bool `yaml:"enabled"`
+	// Commits is the number of recent commits of every top level directory that are considered
+	Commits int `yaml:"commits"`
+}
+
+// SuggestCodeOwners proposes an owning team for every top level directory of the branch, based on which
+// team the authors of the most recent commits to the directory belong to. The maintainer team is not
+// suggested, as it already owns every file
+func (s *ShepardBot) SuggestCodeOwners(repo *github.Repository, branch *github.Branch) ([]CodeOwnersRule, error) {
+	settings, err := s.Settings(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	maintainer, err := s.maintainerTeam(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, _, err := s.gClient.Git.GetTree(s.ctx, *repo.Owner.Login, *repo.Name, branch.Commit.GetSHA(), false)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []CodeOwnersRule
+	for _, entry := range tree.Entries {
+		if entry.GetType() != "tree" {
+			continue
+		}
+
+		authors, err := s.recentAuthors(repo, branch, entry.GetPath(), settings.CodeOwners.Suggest.Commits)
+		if err != nil {
+			return nil, err
+		}
+
+		team, err := s.owningTeam(authors, maintainer)
+		if err != nil {
+			return nil, err
+		}
+
+		if team != nil {
+			rules = append(rules, CodeOwnersRule{
+				Path:   fmt.Sprintf("/%s/", entry.GetPath()),
+				Owners: []string{fmt.Sprintf("@%s/%s", s.org.GetLogin(), team.GetSlug())},
+			})
+		}
+	}
+
+	return rules, nil
+}
+
+// recentAuthors counts the commits of every author in the most recent commits touching the path
+func (s *ShepardBot) recentAuthors(repo *github.Repository, branch *github.Branch, path string, commits int) (map[string]int, error) {
+	opt := &github.CommitsListOptions{
+		SHA:         branch.GetName(),
+		Path:        path,
+		ListOptions: github.ListOptions{PerPage: commits},
+	}
+
+	list, _, err := s.gClient.Repositories.ListCommits(s.ctx, *repo.Owner.Login, *repo.Name, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	authors := map[string]int{}
+	for _, commit := range list {
+		// commits from authors without a GitHub account can't be mapped to a team
+		if login := commit.GetAuthor().GetLogin(); login != "" {
+			authors[login]++
+		}
+	}
+
+	return authors, nil
+}
+
+// owningTeam returns the most specific team whose members authored the most commits, or nil if none of the
+// authors are in a team
+func (s *ShepardBot) owningTeam(authors map[string]int, exclude *github.Team) (*github.Team, error) {
+	var teams []*github.Team
+	members := map[int64][]string{}
+
+	for _, team := range s.teams {
+		if team.GetID() == exclude.GetID() {
+			continue
+		}
+
+		m, err := s.teamMembers(team)
+		if err != nil {
+			return nil, err
+		}
+
+		teams = append(teams, team)
+		members[team.GetID()] = m
+	}
+
+	return pickOwningTeam(teams, members, authors), nil
+}
+
+// pickOwningTeam returns the team whose members authored the most commits. The members of a team include
+// the members of its child teams, so a team that contains every member of another team with commits (such
+// as a parent team or a team of everyone) is never picked over that more specific team
+func pickOwningTeam(teams []*github.Team, members map[int64][]string, authors map[string]int) *github.Team {
+	commits := map[int64]int{}
+	var candidates []*github.Team
+
+	for _, team := range teams {
+		for _, member := range members[team.GetID()] {
+			for author, n := range authors {
+				if strings.EqualFold(author, member) {
+					commits[team.GetID()] += n
+				}
+			}
+		}
+
+		if commits[team.GetID()] > 0 {
+			candidates = append(candidates, team)
+		}
+	}
+
+	var specific []*github.Team
+	for _, team := range candidates {
+		superset := false
+		for _, other := range candidates {
+			if other.GetID() != team.GetID() && strictSubset(members[other.GetID()], members[team.GetID()]) {
+				superset = true
+				break
+			}
+		}
+
+		if !superset {
+			specific = append(specific, team)
+		}
+	}
+
+	// ties go to the smaller team, then the team name, so the same team is picked on every run
+	sort.Slice(specific, func(i, j int) bool {
+		a, b := specific[i], specific[j]
+		if commits[a.GetID()] != commits[b.GetID()] {
+			return commits[a.GetID()] > commits[b.GetID()]
+		}
+		if len(members[a.GetID()]) != len(members[b.GetID()]) {
+			return len(members[a.GetID()]) < len(members[b.GetID()])
+		}
+		return a.GetSlug() < b.GetSlug()
+	})
+
+	if len(specific) == 0 {
+		return nil
+	}
+	return specific[0]
+}
+
+// strictSubset returns true if every member of a is a member of b, and b has more members
+func strictSubset(a, b []string) bool {
+	if len(a) >= len(b) {
+		return false
+	}
+
+	for _, member := range a {
+		if !containsFold(b, member) {
+			return false
+		}
+	}
+	return true
+}
+
+// formatSuggestion renders the suggested rules as a CODEOWNERS snippet for a PR body
+func formatSuggestion(rules []CodeOwnersRule) string {
+	var lines []string
+	for _, rule := range rules {
+		lines = append(lines, fmt.Sprintf("%s %s", rule.Path, strings.Join(rule.Owners, " ")))
+	}
+
+	return fmt.Sprintf("Based on the recent commit history, these directories appear to be owned by the following teams. If they look right, add them to the CODEOWNERS file in this PR:\n\n```\n%s\n```", strings.Join(lines, "\n"))
+}
@@ -0,0 +1,47 @@
+package shepherd
+
+import (
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestPickOwningTeam(t *testing.T) {
+	team := func(id int64, slug string) *github.Team {
+		return &github.Team{ID: github.Int64(id), Slug: github.String(slug)}
+	}
+
+	everyone, backend, frontend, api, ops := team(1, "everyone"), team(2, "backend"), team(3, "frontend"), team(4, "api"), team(5, "ops")
+	teams := []*github.Team{everyone, backend, frontend, api, ops}
+	members := map[int64][]string{
+		1: {"alice", "bob", "carol", "dave"},
+		2: {"alice", "bob"},
+		3: {"carol", "dave"},
+		4: {"bob"},
+		5: {"alice", "eve", "frank"},
+	}
+
+	tests := []struct {
+		name    string
+		authors map[string]int
+		want    *github.Team
+	}{
+		{name: "no commits", authors: map[string]int{}},
+		{name: "authors outside any team", authors: map[string]int{"mallory": 5}},
+		{name: "child team over its parent", authors: map[string]int{"carol": 3, "dave": 2}, want: frontend},
+		{name: "most specific team", authors: map[string]int{"bob": 4}, want: api},
+		{name: "most commits among specific teams", authors: map[string]int{"alice": 1, "carol": 3}, want: frontend},
+		{name: "case insensitive logins", authors: map[string]int{"Carol": 2}, want: frontend},
+		{name: "ties go to the smaller team", authors: map[string]int{"alice": 2}, want: backend},
+		{name: "ties go to the name", authors: map[string]int{"alice": 2, "carol": 2}, want: backend},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pickOwningTeam(teams, members, tt.authors)
+			if got.GetSlug() != tt.want.GetSlug() {
+				t.Errorf("pickOwningTeam() = %q, want %q", got.GetSlug(), tt.want.GetSlug())
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package shepherd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/google/go-github/github"
+)
+
+// CodeOwnersRule assigns owners to a path of the repo, owners can be a team of the org (core or org/core),
+// a user (@someone) or an email address
+type CodeOwnersRule struct {
+	Path   string   `yaml:"path"`
+	Owners []string `yaml:"owners"`
+}
+
+// codeOwnersData is the data available to a CODEOWNERS template
+type codeOwnersData struct {
+	Org        string
+	Repo       string
+	FullName   string
+	Language   string
+	Topics     []string
+	Maintainer string
+	Owners     []string
+	Rules      []CodeOwnersRule
+}
+
+// codeOwnersFuncs are the functions available to a CODEOWNERS template, they are replaced per repo when
+// the template is rendered
+var codeOwnersFuncs = template.FuncMap{
+	"owner": func(name string) (string, error) { return "", nil },
+	"join":  strings.Join,
+}
+
+// defaultCodeOwnersTemplate renders the catch-all rule followed by any path rules from the policy
+const defaultCodeOwnersTemplate = `* {{ join .Owners " " }}
+{{- range .Rules }}
+{{ .Path }}{{ range .Owners }} {{ owner . }}{{ end }}
+{{- end }}
+`
+
+func parseCodeOwnersTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = defaultCodeOwnersTemplate
+	}
+	return template.New("CODEOWNERS").Funcs(codeOwnersFuncs).Option("missingkey=error").Parse(text)
+}
+
+// ownerRef converts an owner from the policy to a CODEOWNERS reference, users and emails are used as is
+// while anything else is looked up as a team of the org
+func (s *ShepardBot) ownerRef(owner string) (string, error) {
+	isUser := strings.HasPrefix(owner, "@") && !strings.Contains(owner, "/")
+	isEmail := !strings.HasPrefix(owner, "@") && strings.Contains(owner, "@")
+	if isUser || isEmail {
+		return owner, nil
+	}
+
+	team, err := s.findTeam(strings.TrimPrefix(owner, "@"))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("@%s/%s", s.org.GetLogin(), team.GetSlug()), nil
+}
+
+// renderCodeOwners renders the CODEOWNERS file for the repo from the policy
+func (s *ShepardBot) renderCodeOwners(repo *github.Repository) ([]byte, error) {
+	settings, err := s.Settings(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	maintainer, err := s.ownerRef(settings.Maintainer)
+	if err != nil {
+		return nil, err
+	}
+
+	owners := []string{maintainer}
+	for _, teamName := range settings.CodeOwners.Teams {
+		ref, err := s.ownerRef(teamName)
+		if err != nil {
+			return nil, err
+		}
+		owners = append(owners, ref)
+	}
+
+	tmpl, err := parseCodeOwnersTemplate(settings.CodeOwners.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl.Funcs(template.FuncMap{
+		"owner": s.ownerRef,
+	})
+
+	data := codeOwnersData{
+		Org:        s.org.GetLogin(),
+		Repo:       repo.GetName(),
+		FullName:   repo.GetFullName(),
+		Language:   repo.GetLanguage(),
+		Topics:     repo.Topics,
+		Maintainer: maintainer,
+		Owners:     owners,
+		Rules:      settings.CodeOwners.Rules,
+	}
+
+	buf := new(bytes.Buffer)
+	err = tmpl.Execute(buf, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: unable to render CODEOWNERS: %v", repo.GetFullName(), err)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,168 @@
+package shepherd
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// CollaboratorSettings audits the outside collaborators of the repo, collaborators that are not members
+// of the org
+type CollaboratorSettings struct {
+	Audit bool `yaml:"audit"`
+	// Allow lists the logins of the outside collaborators that may have (non admin) access to the repo
+	Allow []string `yaml:"allow"`
+	// Remove removes the flagged outside collaborators from the repo
+	Remove bool `yaml:"remove"`
+	// Direct audits the org members with direct access to the repo
+	Direct DirectGrantSettings `yaml:"direct"`
+}
+
+// Collaborator is an outside collaborator of the repo, flagged if it is not allowed or is an admin
+type Collaborator struct {
+	Login      string
+	Permission string
+	Flagged    bool
+	Reason     string
+}
+
+// userPermission returns the highest permission the collaborator has on the repo
+func userPermission(user *github.User) string {
+	permissions := user.GetPermissions()
+	for _, permission := range teamPermissions {
+		if permissions[permission] {
+			return permission
+		}
+	}
+	return ""
+}
+
+// outsideCollaborators returns the logins of the outside collaborators of the org, they are only listed once
+func (s *ShepardBot) outsideCollaborators() (map[string]bool, error) {
+	if s.outside != nil {
+		return s.outside, nil
+	}
+
+	outside := map[string]bool{}
+	opt := &github.ListOutsideCollaboratorsOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	for {
+		users, resp, err := s.gClient.Organizations.ListOutsideCollaborators(s.ctx, s.org.GetLogin(), opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, user := range users {
+			outside[strings.ToLower(user.GetLogin())] = true
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	s.outside = outside
+	return outside, nil
+}
+
+// listCollaborators returns the collaborators of the repo with the affiliation (outside, direct or all)
+func (s *ShepardBot) listCollaborators(repo *github.Repository, affiliation string) ([]*github.User, error) {
+	opt := &github.ListCollaboratorsOptions{
+		Affiliation: affiliation,
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	var collaborators []*github.User
+
+	for {
+		users, resp, err := s.gClient.Repositories.ListCollaborators(s.ctx, *repo.Owner.Login, *repo.Name, opt)
+		if err != nil {
+			return nil, err
+		}
+		collaborators = append(collaborators, users...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return collaborators, nil
+}
+
+// CheckOutsideCollaborators returns every outside collaborator of the repo with its permission, collaborators
+// that are not on the allow list or are admins are flagged
+func (s *ShepardBot) CheckOutsideCollaborators(repo *github.Repository) ([]Collaborator, error) {
+	settings, err := s.Settings(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	outside, err := s.outsideCollaborators()
+	if err != nil {
+		return nil, err
+	}
+
+	// an org without outside collaborators has none on any of its repos
+	if len(outside) == 0 {
+		return nil, nil
+	}
+
+	users, err := s.listCollaborators(repo, "outside")
+	if err != nil {
+		return nil, err
+	}
+
+	var collaborators []Collaborator
+	for _, user := range users {
+		if !outside[strings.ToLower(user.GetLogin())] {
+			continue
+		}
+
+		c := Collaborator{Login: user.GetLogin(), Permission: userPermission(user)}
+		switch {
+		case c.Permission == "admin":
+			c.Flagged, c.Reason = true, "outside collaborators may not be admin"
+		case !containsFold(settings.Collaborators.Allow, c.Login):
+			c.Flagged, c.Reason = true, "not on the allow list"
+		}
+		collaborators = append(collaborators, c)
+	}
+
+	sort.Slice(collaborators, func(i, j int) bool { return collaborators[i].Login < collaborators[j].Login })
+	return collaborators, nil
+}
+
+// DoRemoveCollaborators removes the collaborators from the repo
+func (s *ShepardBot) DoRemoveCollaborators(repo *github.Repository, collaborators []Collaborator) error {
+	for _, c := range collaborators {
+		err := s.record(SnapshotEntry{
+			Kind:       SnapshotCollaborator,
+			Repo:       repo.GetFullName(),
+			User:       c.Login,
+			Permission: c.Permission,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = s.gClient.Repositories.RemoveCollaborator(s.ctx, *repo.Owner.Login, *repo.Name, c.Login)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
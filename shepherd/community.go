@@ -0,0 +1,233 @@
+package shepherd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// Items of the community profile of a repo
+const (
+	CommunityReadme              = "readme"
+	CommunityLicense             = "license"
+	CommunityContributing        = "contributing"
+	CommunityCodeOfConduct       = "code_of_conduct"
+	CommunityIssueTemplate       = "issue_template"
+	CommunityPullRequestTemplate = "pull_request_template"
+)
+
+var communityItems = []string{
+	CommunityReadme,
+	CommunityLicense,
+	CommunityContributing,
+	CommunityCodeOfConduct,
+	CommunityIssueTemplate,
+	CommunityPullRequestTemplate,
+}
+
+// CommunitySettings configures the community profile every repo is required to have
+type CommunitySettings struct {
+	// Required are the items of the community profile a repo must have
+	Required []string `yaml:"required"`
+	// Dir is a local directory of text/templates that are added to a repo for missing items, the item
+	// of a template is derived from its name (e.g. LICENSE, .github/CONTRIBUTING.md or .github/ISSUE_TEMPLATE/bug.md)
+	Dir string `yaml:"dir"`
+}
+
+// CommunityHealth is the community profile of a repo compared to the required items
+type CommunityHealth struct {
+	Percentage int
+	Missing    []string
+	// Files are the templates that add the missing items, NoTemplate are the missing items without a template
+	Files      []ManagedFile
+	NoTemplate []string
+}
+
+// communityProfile is the community profile response, which has more files than go-github knows about
+type communityProfile struct {
+	HealthPercentage int                       `json:"health_percentage"`
+	Files            map[string]*github.Metric `json:"files"`
+}
+
+func (c *CommunitySettings) validate() error {
+	for _, item := range c.Required {
+		if !containsString(communityItems, item) {
+			return fmt.Errorf("community: unknown item %q, expected one of %s", item, strings.Join(communityItems, ", "))
+		}
+	}
+
+	return validateDir("community", c.Dir)
+}
+
+// communityItem returns the community profile item a file provides, or an empty string
+func communityItem(repoPath string) string {
+	name := strings.ToUpper(path.Base(repoPath))
+	name = strings.TrimSuffix(name, path.Ext(name))
+
+	switch {
+	case strings.Contains(strings.ToUpper(repoPath), "ISSUE_TEMPLATE"):
+		return CommunityIssueTemplate
+	case name == "PULL_REQUEST_TEMPLATE":
+		return CommunityPullRequestTemplate
+	case name == "README":
+		return CommunityReadme
+	case name == "LICENSE" || name == "LICENCE" || name == "COPYING":
+		return CommunityLicense
+	case name == "CONTRIBUTING":
+		return CommunityContributing
+	case name == "CODE_OF_CONDUCT":
+		return CommunityCodeOfConduct
+	default:
+		return ""
+	}
+}
+
+// ErrNoCommunityProfile is returned for repos GitHub has no community profile for, such as forks
+var ErrNoCommunityProfile = errors.New("GitHub has no community profile for the repo")
+
+// communityDirs are the directories GitHub looks in for the files of the community profile
+var communityDirs = []string{"", ".github", "docs"}
+
+// localProfile builds the community profile of a repo from the files of the branch, GitHub only provides
+// the profile of public repos. The percentage is the share of the community items the repo has
+func (s *ShepardBot) localProfile(repo *github.Repository, branch *github.Branch) (*communityProfile, error) {
+	profile := &communityProfile{Files: map[string]*github.Metric{}}
+
+	for _, dir := range communityDirs {
+		_, entries, resp, err := s.gClient.Repositories.GetContents(
+			s.ctx,
+			*repo.Owner.Login,
+			*repo.Name,
+			dir,
+			&github.RepositoryContentGetOptions{Ref: branch.GetName()},
+		)
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			if item := communityItem(entry.GetPath()); item != "" {
+				profile.Files[item] = &github.Metric{Name: entry.Name, HTMLURL: entry.HTMLURL}
+			}
+		}
+	}
+
+	profile.HealthPercentage = len(profile.Files) * 100 / len(communityItems)
+	return profile, nil
+}
+
+// CheckCommunityHealth compares the community profile of the repo with the required items, the templates
+// for the missing items are rendered against the branch
+func (s *ShepardBot) CheckCommunityHealth(repo *github.Repository, branch *github.Branch) (*CommunityHealth, error) {
+	settings, err := s.Settings(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	// the community profile is not available for forks
+	if repo.GetFork() {
+		return nil, ErrNoCommunityProfile
+	}
+
+	profile, err := s.communityProfile(repo, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	health := &CommunityHealth{Percentage: profile.HealthPercentage}
+	for _, item := range settings.Community.Required {
+		if profile.Files[item] == nil {
+			health.Missing = append(health.Missing, item)
+		}
+	}
+
+	if len(health.Missing) == 0 || settings.Community.Dir == "" {
+		health.NoTemplate = health.Missing
+		return health, nil
+	}
+
+	files, err := s.renderTemplates(repo, branch, settings.Community.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	covered := map[string]bool{}
+	for _, f := range files {
+		item := communityItem(f.path)
+		if !containsString(health.Missing, item) {
+			continue
+		}
+		covered[item] = true
+
+		// the profile doesn't recognise every file (e.g. an unknown license), these are never overwritten
+		_, found, err := s.getFile(repo, f.path, branch.GetName())
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			continue
+		}
+
+		health.Files = append(health.Files, ManagedFile{Path: f.path, content: f.content})
+	}
+
+	for _, item := range health.Missing {
+		if !covered[item] {
+			health.NoTemplate = append(health.NoTemplate, item)
+		}
+	}
+
+	sort.Strings(health.NoTemplate)
+	return health, nil
+}
+
+// communityProfile returns the community profile GitHub has for a public repo, the profile of a private
+// repo is built from its files
+func (s *ShepardBot) communityProfile(repo *github.Repository, branch *github.Branch) (*communityProfile, error) {
+	if repo.GetPrivate() {
+		return s.localProfile(repo, branch)
+	}
+
+	u := fmt.Sprintf("repos/%v/%v/community/profile", *repo.Owner.Login, *repo.Name)
+	req, err := s.gClient.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github.black-panther-preview+json")
+
+	profile := &communityProfile{}
+	resp, err := s.gClient.Do(s.ctx, req, profile)
+	if resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusForbidden) {
+		return nil, ErrNoCommunityProfile
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+// DoCommunityHealth opens a PR adding the templates for the missing community profile items
+func (s *ShepardBot) DoCommunityHealth(repo *github.Repository, branch *github.Branch, health *CommunityHealth) (*github.PullRequest, error) {
+	var files []changedFile
+	var summary []string
+	for _, f := range health.Files {
+		files = append(files, changedFile{path: f.Path, content: f.content})
+		summary = append(summary, fmt.Sprintf("- `%s` (%s)", f.Path, communityItem(f.Path)))
+	}
+
+	return s.proposeChange(repo, branch, &fileChange{
+		kind:    PRCommunity,
+		files:   files,
+		details: strings.Join(summary, "\n"),
+	})
+}
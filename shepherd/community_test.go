@@ -0,0 +1,32 @@
+package shepherd
+
+import "testing"
+
+func TestCommunityItem(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "README.md", want: CommunityReadme},
+		{path: "docs/readme.rst", want: CommunityReadme},
+		{path: "LICENSE", want: CommunityLicense},
+		{path: "LICENCE.txt", want: CommunityLicense},
+		{path: "COPYING", want: CommunityLicense},
+		{path: ".github/CONTRIBUTING.md", want: CommunityContributing},
+		{path: "CODE_OF_CONDUCT.md", want: CommunityCodeOfConduct},
+		{path: ".github/ISSUE_TEMPLATE/bug_report.md", want: CommunityIssueTemplate},
+		{path: ".github/ISSUE_TEMPLATE", want: CommunityIssueTemplate},
+		{path: "docs/issue_template.md", want: CommunityIssueTemplate},
+		{path: ".github/PULL_REQUEST_TEMPLATE.md", want: CommunityPullRequestTemplate},
+		{path: "main.go", want: ""},
+		{path: "docs/README-dev.md", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := communityItem(tt.path); got != tt.want {
+				t.Errorf("communityItem(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package shepherd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// DirectGrantSettings audits the org members that have been granted access to the repo directly rather
+// than through a team
+type DirectGrantSettings struct {
+	Audit bool `yaml:"audit"`
+	// Fix removes direct grants once a team gives the member the same access
+	Fix bool `yaml:"fix"`
+}
+
+// DirectGrant is an org member with direct access to the repo. GitHub only reports the access a member
+// has on the repo (Permission), which is the highest of the direct grant, the access the member gets
+// through its teams (TeamPermission) and the base permission of the org (BasePermission)
+type DirectGrant struct {
+	Login          string
+	Permission     string
+	TeamPermission string
+	BasePermission string
+}
+
+// Bypasses returns true if the direct grant gives more access than the teams of the member and the base
+// permission of the org, the direct grant is then exactly the access the member has
+func (g DirectGrant) Bypasses() bool {
+	return permissionRank(g.Permission) < permissionRank(highestPermission(g.TeamPermission, g.BasePermission))
+}
+
+// Covered returns true if the teams of the member give it at least the access it has, so the direct grant
+// can be removed without the member losing access
+func (g DirectGrant) Covered() bool {
+	return g.TeamPermission != "" && permissionRank(g.TeamPermission) <= permissionRank(g.Permission)
+}
+
+// permissionRank returns the position of the permission in teamPermissions, a lower rank is more access
+func permissionRank(permission string) int {
+	for i, p := range teamPermissions {
+		if p == permission {
+			return i
+		}
+	}
+	return len(teamPermissions)
+}
+
+// highestPermission returns the permission that gives the most access
+func highestPermission(a, b string) string {
+	if permissionRank(a) < permissionRank(b) {
+		return a
+	}
+	return b
+}
+
+// lowestPermission returns the permission that gives the least access, no access is the lowest
+func lowestPermission(a, b string) string {
+	if permissionRank(a) > permissionRank(b) {
+		return a
+	}
+	return b
+}
+
+// orgBasePermissions maps the base permission of an org to the matching repo permission
+var orgBasePermissions = map[string]string{
+	"read":  "pull",
+	"write": "push",
+	"admin": "admin",
+}
+
+// basePermission returns the repo permission every member of the org has, or an empty string if members
+// get no access by default
+func (s *ShepardBot) basePermission() (string, error) {
+	if s.base != nil {
+		return *s.base, nil
+	}
+
+	// the base permission is not part of the organization of the github client
+	req, err := s.gClient.NewRequest("GET", fmt.Sprintf("orgs/%v", s.org.GetLogin()), nil)
+	if err != nil {
+		return "", err
+	}
+
+	org := &struct {
+		DefaultRepositoryPermission string `json:"default_repository_permission"`
+	}{}
+	_, err = s.gClient.Do(s.ctx, req, org)
+	if err != nil {
+		return "", err
+	}
+
+	base := orgBasePermissions[org.DefaultRepositoryPermission]
+	s.base = &base
+	return base, nil
+}
+
+// teamDerivedPermissions returns the highest permission every member of a team with access to the repo
+// gets through its teams. With the team rule enabled the teams are taken as the policy leaves them: teams
+// that are about to be revoked give no access and teams about to be changed give the lower permission
+func (s *ShepardBot) teamDerivedPermissions(repo *github.Repository) (map[string]string, error) {
+	settings, err := s.Settings(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var desired map[string]string
+	if settings.RuleEnabled(RuleTeam) {
+		desired, _, err = s.desiredTeamAccess(repo)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	permissions := map[string]string{}
+	opt := &github.ListOptions{PerPage: 100}
+
+	for {
+		teams, resp, err := s.gClient.Repositories.ListTeams(s.ctx, *repo.Owner.Login, *repo.Name, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, team := range teams {
+			permission := team.GetPermission()
+			if d, ok := desired[team.GetSlug()]; ok {
+				permission = lowestPermission(permission, d)
+			} else if desired != nil && settings.Teams.Authoritative {
+				permission = ""
+			}
+
+			if permission == "" {
+				continue
+			}
+
+			members, err := s.teamMembers(team)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, member := range members {
+				login := strings.ToLower(member)
+				permissions[login] = highestPermission(permissions[login], permission)
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return permissions, nil
+}
+
+// CheckDirectGrants returns every org member that has been granted access to the repo directly, with the
+// access the member gets through its teams. Outside collaborators are left to CheckOutsideCollaborators
+func (s *ShepardBot) CheckDirectGrants(repo *github.Repository) ([]DirectGrant, error) {
+	outside, err := s.outsideCollaborators()
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := s.basePermission()
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := s.listCollaborators(repo, "direct")
+	if err != nil {
+		return nil, err
+	}
+
+	derived, err := s.teamDerivedPermissions(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var grants []DirectGrant
+	for _, user := range users {
+		login := strings.ToLower(user.GetLogin())
+		if outside[login] {
+			continue
+		}
+
+		grants = append(grants, DirectGrant{
+			Login:          user.GetLogin(),
+			Permission:     userPermission(user),
+			TeamPermission: derived[login],
+			BasePermission: base,
+		})
+	}
+
+	sort.Slice(grants, func(i, j int) bool { return grants[i].Login < grants[j].Login })
+	return grants, nil
+}
+
+// DoRemoveDirectGrants removes the direct grants that are covered by the teams of the member, grants that
+// give more access than the teams are kept so nobody loses access
+func (s *ShepardBot) DoRemoveDirectGrants(repo *github.Repository, grants []DirectGrant) ([]DirectGrant, error) {
+	var removed []DirectGrant
+
+	for _, g := range grants {
+		if !g.Covered() {
+			continue
+		}
+
+		// the level of a covered grant cannot be read from the API, it is at most the access the member
+		// has. A rollback restores it at the lowest level, so it never grants more than the member had
+		err := s.record(SnapshotEntry{
+			Kind:       SnapshotCollaborator,
+			Repo:       repo.GetFullName(),
+			User:       g.Login,
+			Permission: "pull",
+		})
+		if err != nil {
+			return removed, err
+		}
+
+		_, err = s.gClient.Repositories.RemoveCollaborator(s.ctx, *repo.Owner.Login, *repo.Name, g.Login)
+		if err != nil {
+			return removed, err
+		}
+		removed = append(removed, g)
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,81 @@
+package shepherd
+
+import "testing"
+
+func TestDirectGrant(t *testing.T) {
+	tests := []struct {
+		name     string
+		grant    DirectGrant
+		bypasses bool
+		covered  bool
+	}{
+		{
+			name:     "no team",
+			grant:    DirectGrant{Login: "octocat", Permission: "push"},
+			bypasses: true,
+		},
+		{
+			name:     "more access than the team",
+			grant:    DirectGrant{Login: "octocat", Permission: "admin", TeamPermission: "push"},
+			bypasses: true,
+		},
+		{
+			name:    "same access as the team",
+			grant:   DirectGrant{Login: "octocat", Permission: "push", TeamPermission: "push"},
+			covered: true,
+		},
+		{
+			name:    "team gives more access",
+			grant:   DirectGrant{Login: "octocat", Permission: "maintain", TeamPermission: "admin"},
+			covered: true,
+		},
+		{
+			name:  "same access as the base permission",
+			grant: DirectGrant{Login: "octocat", Permission: "pull", BasePermission: "pull"},
+		},
+		{
+			name:     "more access than the base permission",
+			grant:    DirectGrant{Login: "octocat", Permission: "push", BasePermission: "pull"},
+			bypasses: true,
+		},
+		{
+			name:  "base permission gives more access than the team",
+			grant: DirectGrant{Login: "octocat", Permission: "push", TeamPermission: "triage", BasePermission: "push"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.grant.Bypasses(); got != tt.bypasses {
+				t.Errorf("Bypasses() = %v, want %v", got, tt.bypasses)
+			}
+			if got := tt.grant.Covered(); got != tt.covered {
+				t.Errorf("Covered() = %v, want %v", got, tt.covered)
+			}
+		})
+	}
+}
+
+func TestPermissionOrder(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		highest string
+		lowest  string
+	}{
+		{a: "admin", b: "pull", highest: "admin", lowest: "pull"},
+		{a: "triage", b: "maintain", highest: "maintain", lowest: "triage"},
+		{a: "push", b: "push", highest: "push", lowest: "push"},
+		{a: "", b: "pull", highest: "pull", lowest: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			if got := highestPermission(tt.a, tt.b); got != tt.highest {
+				t.Errorf("highestPermission(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.highest)
+			}
+			if got := lowestPermission(tt.a, tt.b); got != tt.lowest {
+				t.Errorf("lowestPermission(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.lowest)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package shepherd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Markers delimiting the region of a file that shepherd manages, everything outside of them belongs to the repo
+const (
+	blockBegin = "# BEGIN SHEPHERD MANAGED"
+	blockEnd   = "# END SHEPHERD MANAGED"
+)
+
+// applyManagedBlock returns the content with the lines between the markers replaced by the block. If the
+// content has no managed block yet, it is appended to the end of the content
+func applyManagedBlock(content string, block string) (string, error) {
+	if block != "" && !strings.HasSuffix(block, "\n") {
+		block += "\n"
+	}
+	managed := blockBegin + "\n" + block + blockEnd + "\n"
+
+	lines := strings.SplitAfter(content, "\n")
+	begin, end := -1, -1
+	for i, line := range lines {
+		switch strings.TrimSpace(line) {
+		case blockBegin:
+			if begin >= 0 {
+				return "", fmt.Errorf("more than one %q marker", blockBegin)
+			}
+			begin = i
+		case blockEnd:
+			if begin < 0 || end >= 0 {
+				return "", fmt.Errorf("unexpected %q marker", blockEnd)
+			}
+			end = i
+		}
+	}
+
+	if begin >= 0 && end < 0 {
+		return "", fmt.Errorf("%q marker without %q", blockBegin, blockEnd)
+	}
+
+	if begin < 0 {
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		if content != "" {
+			content += "\n"
+		}
+		return content + managed, nil
+	}
+
+	return strings.Join(lines[:begin], "") + managed + strings.Join(lines[end+1:], ""), nil
+}
@@ -0,0 +1,69 @@
+package shepherd
+
+import "testing"
+
+func TestApplyManagedBlock(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		block   string
+		want    string
+		err     bool
+	}{
+		{
+			name:  "empty file",
+			block: "*.log",
+			want:  "# BEGIN SHEPHERD MANAGED\n*.log\n# END SHEPHERD MANAGED\n",
+		},
+		{
+			name:    "appended to the content",
+			content: "bin/",
+			block:   "*.log\n",
+			want:    "bin/\n\n# BEGIN SHEPHERD MANAGED\n*.log\n# END SHEPHERD MANAGED\n",
+		},
+		{
+			name:    "replaced between the markers",
+			content: "bin/\n# BEGIN SHEPHERD MANAGED\n*.tmp\n# END SHEPHERD MANAGED\nvendor/\n",
+			block:   "*.log\n",
+			want:    "bin/\n# BEGIN SHEPHERD MANAGED\n*.log\n# END SHEPHERD MANAGED\nvendor/\n",
+		},
+		{
+			name:    "indented markers",
+			content: "  # BEGIN SHEPHERD MANAGED\n*.tmp\n  # END SHEPHERD MANAGED\n",
+			block:   "*.log",
+			want:    "# BEGIN SHEPHERD MANAGED\n*.log\n# END SHEPHERD MANAGED\n",
+		},
+		{
+			name:    "empty block",
+			content: "# BEGIN SHEPHERD MANAGED\n*.tmp\n# END SHEPHERD MANAGED\n",
+			want:    "# BEGIN SHEPHERD MANAGED\n# END SHEPHERD MANAGED\n",
+		},
+		{
+			name:    "begin without end",
+			content: "# BEGIN SHEPHERD MANAGED\n*.tmp\n",
+			err:     true,
+		},
+		{
+			name:    "end without begin",
+			content: "*.tmp\n# END SHEPHERD MANAGED\n",
+			err:     true,
+		},
+		{
+			name:    "two blocks",
+			content: "# BEGIN SHEPHERD MANAGED\n# END SHEPHERD MANAGED\n# BEGIN SHEPHERD MANAGED\n# END SHEPHERD MANAGED\n",
+			err:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyManagedBlock(tt.content, tt.block)
+			if (err != nil) != tt.err {
+				t.Fatalf("applyManagedBlock() error = %v, want error %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("applyManagedBlock() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package shepherd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/sirupsen/logrus"
+)
+
+// FilesSettings configures the files (LICENSE, SECURITY.md, issue templates...) shepherd keeps in sync
+// across the repos
+type FilesSettings struct {
+	// Dir is a local directory of text/templates, every file in it is rendered and written to the same
+	// path in the repo
+	Dir string `yaml:"dir"`
+	// Blocks are globs of paths in Dir that are only a managed block of the file in the repo, the block is
+	// kept between "# BEGIN SHEPHERD MANAGED" and "# END SHEPHERD MANAGED" and the rest of the file is left alone
+	Blocks []string `yaml:"blocks"`
+}
+
+// ManagedFile is a file of the template directory that is missing or out of date in a repo
+type ManagedFile struct {
+	Path   string
+	Exists bool
+	// Block is true when only the managed block of the file is out of date
+	Block bool
+
+	content []byte
+}
+
+// managedFileData is the data available to the templates of managed files
+type managedFileData struct {
+	Org         string
+	Repo        string
+	FullName    string
+	Description string
+	Language    string
+	Topics      []string
+	Branch      string
+	Maintainer  string
+	Year        int
+}
+
+func (f *FilesSettings) validate() error {
+	for _, glob := range f.Blocks {
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("files: invalid blocks glob %q: %v", glob, err)
+		}
+	}
+
+	return validateDir("files", f.Dir)
+}
+
+// validateDir verifies the template directory of the setting exists, if it is set
+func validateDir(setting string, dir string) error {
+	if dir == "" {
+		return nil
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("%s: %v", setting, err)
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("%s: %s is not a directory", setting, dir)
+	}
+	return nil
+}
+
+// managedTemplates returns the templates of the directory by their path in the repo
+func managedTemplates(dir string) (map[string]*template.Template, error) {
+	templates := map[string]*template.Template{}
+
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		repoPath := filepath.ToSlash(rel)
+
+		text, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		tmpl, err := template.New(repoPath).Funcs(codeOwnersFuncs).Option("missingkey=error").Parse(string(text))
+		if err != nil {
+			return fmt.Errorf("files: %v", err)
+		}
+
+		templates[repoPath] = tmpl
+		return nil
+	})
+
+	return templates, err
+}
+
+// renderTemplates renders every template of the directory for the repo
+func (s *ShepardBot) renderTemplates(repo *github.Repository, branch *github.Branch, dir string) ([]changedFile, error) {
+	settings, err := s.Settings(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	maintainer, err := s.ownerRef(settings.Maintainer)
+	if err != nil {
+		return nil, err
+	}
+
+	templates, err := managedTemplates(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	data := managedFileData{
+		Org:         s.org.GetLogin(),
+		Repo:        repo.GetName(),
+		FullName:    repo.GetFullName(),
+		Description: repo.GetDescription(),
+		Language:    repo.GetLanguage(),
+		Topics:      repo.Topics,
+		Branch:      branch.GetName(),
+		Maintainer:  maintainer,
+		Year:        time.Now().Year(),
+	}
+
+	var files []changedFile
+	for repoPath, tmpl := range templates {
+		tmpl.Funcs(template.FuncMap{
+			"owner": s.ownerRef,
+		})
+
+		buf := new(bytes.Buffer)
+		err = tmpl.Execute(buf, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: unable to render %s: %v", repo.GetFullName(), repoPath, err)
+		}
+
+		files = append(files, changedFile{path: repoPath, content: buf.Bytes()})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+	return files, nil
+}
+
+// matchesAnyGlob returns true if the path matches one of the globs
+func matchesAnyGlob(globs []string, name string) bool {
+	for _, glob := range globs {
+		if matched, _ := path.Match(glob, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckManagedFiles renders the managed files for the repo and returns those that are missing from the
+// branch or have different content
+func (s *ShepardBot) CheckManagedFiles(repo *github.Repository, branch *github.Branch) ([]ManagedFile, error) {
+	settings, err := s.Settings(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := s.renderTemplates(repo, branch, settings.Files.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var outdated []ManagedFile
+	for _, f := range files {
+		content, found, err := s.getFile(repo, f.path, branch.GetName())
+		if err != nil {
+			return nil, err
+		}
+
+		block := matchesAnyGlob(settings.Files.Blocks, f.path)
+		if block {
+			desired, err := applyManagedBlock(content, string(f.content))
+			if err != nil {
+				logrus.Warnf("%s: skipping managed block of %s: %v", repo.GetFullName(), f.path, err)
+				continue
+			}
+			f.content = []byte(desired)
+		}
+
+		if found && content == string(f.content) {
+			continue
+		}
+
+		outdated = append(outdated, ManagedFile{Path: f.path, Exists: found, Block: block && found, content: f.content})
+	}
+
+	return outdated, nil
+}
+
+// DoSyncManagedFiles opens a single PR that adds or updates every outdated managed file in one commit
+func (s *ShepardBot) DoSyncManagedFiles(repo *github.Repository, branch *github.Branch, outdated []ManagedFile) (*github.PullRequest, error) {
+	var files []changedFile
+	var summary []string
+	for _, f := range outdated {
+		files = append(files, changedFile{path: f.Path, content: f.content})
+
+		switch {
+		case f.Block:
+			summary = append(summary, fmt.Sprintf("- the managed block of `%s` is updated", f.Path))
+		case f.Exists:
+			summary = append(summary, fmt.Sprintf("- `%s` is updated", f.Path))
+		default:
+			summary = append(summary, fmt.Sprintf("- `%s` is added", f.Path))
+		}
+	}
+
+	return s.proposeChange(repo, branch, &fileChange{
+		kind:    PRSyncFiles,
+		files:   files,
+		details: strings.Join(summary, "\n"),
+	})
+}
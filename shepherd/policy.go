@@ -0,0 +1,406 @@
+package shepherd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/srizzling/shepherd/codeowners"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Policy describes how shepherd should herd the repositories of an org. It is made up of org-wide
+// defaults and a list of override blocks which are applied (in order) to every repo whose name
+// matches the block's glob
+type Policy struct {
+	Org    string `yaml:"org"`
+	DryRun bool   `yaml:"dryrun"`
+	// Snapshots is the directory the prior state of every setting a run changes is written to
+	Snapshots  string                 `yaml:"snapshots"`
+	Select     Selection              `yaml:"select"`
+	RepoConfig RepoConfigPolicy       `yaml:"repo_config"`
+	Defaults   map[string]interface{} `yaml:"defaults"`
+	Repos      []RepoOverride         `yaml:"repos"`
+}
+
+// RepoOverride is a block of settings that only applies to repos with a name matching Match
+type RepoOverride struct {
+	Match    string
+	Settings map[string]interface{}
+}
+
+// Settings are the effective settings for a single repo, after the policy defaults and any
+// matching overrides have been applied
+type Settings struct {
+	Skip       bool     `yaml:"skip"`
+	Disable    []string `yaml:"disable"`
+	Maintainer string   `yaml:"maintainer"`
+	// MaintainerPermission is the permission the maintainer team has on the repo
+	MaintainerPermission string `yaml:"maintainer_permission"`
+	// Branch is the branch CODEOWNERS and other files are proposed to, the default branch of the repo when empty
+	Branch string `yaml:"branch"`
+	// Branches are the branches that are protected, only Branch is protected when there are none
+	Branches      []BranchSettings     `yaml:"branches"`
+	CodeOwners    CodeOwnersSettings   `yaml:"codeowners"`
+	Protection    ProtectionSettings   `yaml:"protection"`
+	PullRequests  PullRequestSettings  `yaml:"pull_requests"`
+	Files         FilesSettings        `yaml:"files"`
+	Community     CommunitySettings    `yaml:"community"`
+	Teams         TeamSettings         `yaml:"teams"`
+	Collaborators CollaboratorSettings `yaml:"collaborators"`
+}
+
+// Rules that can be disabled for a repo
+const (
+	RuleCodeOwners    = "codeowners"
+	RuleTeam          = "team"
+	RuleProtection    = "protection"
+	RuleFiles         = "files"
+	RuleCommunity     = "community"
+	RuleCollaborators = "collaborators"
+)
+
+var rules = []string{RuleCodeOwners, RuleTeam, RuleProtection, RuleFiles, RuleCommunity, RuleCollaborators}
+
+// CodeOwnersSettings configures the CODEOWNERS file shepherd creates
+type CodeOwnersSettings struct {
+	Path string `yaml:"path"`
+	// Teams are added as owners of every file, next to the maintainer team
+	Teams []string `yaml:"teams"`
+	// Rules assign owners to specific paths of the repo
+	Rules []CodeOwnersRule `yaml:"rules"`
+	// Template is a text/template that replaces the generated CODEOWNERS file
+	Template string `yaml:"template"`
+	// ValidateOwners verifies the owners of an existing CODEOWNERS file can approve changes, FixOwners
+	// opens a PR to remove the owners that cannot
+	ValidateOwners bool `yaml:"validate_owners"`
+	FixOwners      bool `yaml:"fix_owners"`
+	// EnsureMaintainer opens a PR to add the maintainer team to the catch-all rule of an existing CODEOWNERS file
+	EnsureMaintainer bool `yaml:"ensure_maintainer"`
+	// Coverage reports the files of the protected branch that have no owner
+	Coverage bool `yaml:"coverage"`
+	// Suggest proposes per directory owners in the PR that adds a CODEOWNERS file
+	Suggest SuggestSettings `yaml:"suggest"`
+}
+
+// ProtectionSettings configures the branch protection shepherd applies to the protected branch
+type ProtectionSettings struct {
+	// Mode is merge (keep stricter or additional protection the repo already has) or authoritative
+	Mode                     string `yaml:"mode"`
+	RequireCodeOwnerReviews  bool   `yaml:"require_code_owner_reviews"`
+	DismissStaleReviews      bool   `yaml:"dismiss_stale_reviews"`
+	RequiredApprovingReviews int    `yaml:"required_approving_reviews"`
+	// StatusChecks that have to pass before merging, strict requires the branch to be up to date with the base
+	StatusChecks StatusCheckSettings `yaml:"status_checks"`
+	// EnforceAdmins applies the protection to admins too
+	EnforceAdmins bool `yaml:"enforce_admins"`
+	// DismissalRestrictions limit who can dismiss reviews, PushRestrictions limit who can push to the branch.
+	// Nobody is restricted when they are empty
+	DismissalRestrictions Restrictions `yaml:"dismissal_restrictions"`
+	PushRestrictions      Restrictions `yaml:"push_restrictions"`
+}
+
+// StatusCheckSettings are the status checks required by the protected branch
+type StatusCheckSettings struct {
+	Contexts []string `yaml:"contexts"`
+	Strict   bool     `yaml:"strict"`
+	// Discover infers the status checks from the statuses of the latest commits of the branch
+	Discover DiscoverSettings `yaml:"discover"`
+}
+
+// Restrictions are teams (of the org) and users (logins) that are allowed to do something
+type Restrictions struct {
+	Teams []string `yaml:"teams"`
+	Users []string `yaml:"users"`
+}
+
+// defaultSettings returns the settings shepherd has always used, these are the base any policy is applied on top of
+func defaultSettings() *Settings {
+	return &Settings{
+		MaintainerPermission: "admin",
+		CodeOwners: CodeOwnersSettings{
+			Path: ".github/CODEOWNERS",
+			Suggest: SuggestSettings{
+				Commits: 30,
+			},
+		},
+		Protection: ProtectionSettings{
+			Mode:                     ProtectionMerge,
+			RequireCodeOwnerReviews:  true,
+			DismissStaleReviews:      true,
+			RequiredApprovingReviews: 1,
+			StatusChecks: StatusCheckSettings{
+				Discover: DiscoverSettings{
+					Commits:   10,
+					Threshold: 1,
+				},
+			},
+		},
+		PullRequests: PullRequestSettings{
+			RequestReview: true,
+			AutoMerge: AutoMergeSettings{
+				Method: "merge",
+			},
+		},
+	}
+}
+
+// UnmarshalYAML reads an override block, the match key selects the repos and every other key is a setting
+func (o *RepoOverride) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	raw := map[string]interface{}{}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+
+	match, ok := raw["match"].(string)
+	if !ok || match == "" {
+		return errors.New("repos: every override block requires a match glob")
+	}
+	delete(raw, "match")
+
+	o.Match = match
+	o.Settings = raw
+	return nil
+}
+
+// NewPolicy returns an empty policy for the org, which results in the default settings for every repo
+func NewPolicy(org string) *Policy {
+	return &Policy{
+		Org:       org,
+		Snapshots: ".shepherd/snapshots",
+		RepoConfig: RepoConfigPolicy{
+			Path: ".github/shepherd.yml",
+		},
+		Defaults: map[string]interface{}{},
+	}
+}
+
+// LoadPolicy reads a policy file from disk, the policy is not validated until Validate is called
+func LoadPolicy(filename string) (*Policy, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	p := NewPolicy("")
+	if err := yaml.UnmarshalStrict(data, p); err != nil {
+		return nil, fmt.Errorf("unable to parse policy %s: %v", filename, err)
+	}
+
+	if p.Defaults == nil {
+		p.Defaults = map[string]interface{}{}
+	}
+
+	return p, nil
+}
+
+// SetDefault overrides a default setting of the policy, this is used to let cli flags take precedence
+func (p *Policy) SetDefault(key string, value interface{}) {
+	p.Defaults[key] = value
+}
+
+// Validate ensures the policy is usable before any repo is touched
+func (p *Policy) Validate() error {
+	if p.Org == "" {
+		return errors.New("policy: no organization provided")
+	}
+
+	defaults, err := p.SettingsFor("")
+	if err != nil {
+		return err
+	}
+
+	if defaults.Maintainer == "" {
+		return errors.New("policy: no maintainer team provided")
+	}
+
+	if err := p.Select.compile(); err != nil {
+		return fmt.Errorf("policy: %v", err)
+	}
+
+	if p.RepoConfig.Path == "" {
+		return errors.New("policy: repo_config path cannot be empty")
+	}
+
+	if p.Snapshots == "" {
+		return errors.New("policy: snapshots directory cannot be empty")
+	}
+
+	for _, o := range p.Repos {
+		if _, err := path.Match(o.Match, ""); err != nil {
+			return fmt.Errorf("policy: invalid match glob %q: %v", o.Match, err)
+		}
+
+		// verify the override on its own, as it may never match any repo in this run
+		settings := defaults.copy()
+		if err := settings.apply(o.Settings); err != nil {
+			return fmt.Errorf("policy: override %q: %v", o.Match, err)
+		}
+		if err := settings.validate(); err != nil {
+			return fmt.Errorf("policy: override %q: %v", o.Match, err)
+		}
+	}
+
+	return nil
+}
+
+// teams returns the maintainer teams and the teams granted access by the defaults and every override block
+func (p *Policy) teams() ([]string, error) {
+	defaults, err := p.SettingsFor("")
+	if err != nil {
+		return nil, err
+	}
+
+	layers := []*Settings{defaults}
+	for _, o := range p.Repos {
+		settings := defaults.copy()
+		if err := settings.apply(o.Settings); err != nil {
+			return nil, fmt.Errorf("policy: override %q: %v", o.Match, err)
+		}
+		layers = append(layers, settings)
+	}
+
+	var teams []string
+	for _, settings := range layers {
+		for _, team := range settings.teams() {
+			if !containsString(teams, team) {
+				teams = append(teams, team)
+			}
+		}
+	}
+
+	sort.Strings(teams)
+	return teams, nil
+}
+
+// teams returns the maintainer team and the teams granted access to the repo
+func (s *Settings) teams() []string {
+	teams := []string{s.Maintainer}
+	for team := range s.Teams.Grants {
+		teams = append(teams, team)
+	}
+	return teams
+}
+
+// SettingsFor returns the effective settings for the repo with the given name
+func (p *Policy) SettingsFor(repoName string) (*Settings, error) {
+	settings := defaultSettings()
+
+	if err := settings.apply(p.Defaults); err != nil {
+		return nil, fmt.Errorf("policy: defaults: %v", err)
+	}
+
+	for _, o := range p.Repos {
+		matched, err := path.Match(o.Match, repoName)
+		if err != nil {
+			return nil, err
+		}
+
+		if !matched {
+			continue
+		}
+
+		if err := settings.apply(o.Settings); err != nil {
+			return nil, fmt.Errorf("policy: override %q: %v", o.Match, err)
+		}
+	}
+
+	if err := settings.validate(); err != nil {
+		return nil, fmt.Errorf("policy: %s: %v", repoName, err)
+	}
+
+	return settings, nil
+}
+
+// apply layers the raw settings on top of the current ones, keys that are not set are left untouched
+func (s *Settings) apply(raw map[string]interface{}) error {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	data, err := yaml.Marshal(raw)
+	if err != nil {
+		return err
+	}
+
+	return yaml.UnmarshalStrict(data, s)
+}
+
+// RuleEnabled returns false if the rule has been disabled for the repo
+func (s *Settings) RuleEnabled(rule string) bool {
+	return !containsString(s.Disable, rule)
+}
+
+// copy returns a deep copy of the settings which can be modified without changing the original, the
+// settings are round tripped through yaml so maps are not shared with the original
+func (s *Settings) copy() *Settings {
+	c := &Settings{}
+
+	data, err := yaml.Marshal(s)
+	if err == nil {
+		err = yaml.Unmarshal(data, c)
+	}
+
+	if err != nil {
+		// the settings were read from yaml, so this can only be a bug
+		panic(fmt.Sprintf("unable to copy settings: %v", err))
+	}
+	return c
+}
+
+func (s *Settings) validate() error {
+	for _, rule := range s.Disable {
+		if !containsString(rules, rule) {
+			return fmt.Errorf("unknown rule %q, expected one of %s", rule, strings.Join(rules, ", "))
+		}
+	}
+
+	if err := validatePermission(s.MaintainerPermission); err != nil {
+		return fmt.Errorf("maintainer_permission: %v", err)
+	}
+
+	if err := s.Teams.validate(); err != nil {
+		return err
+	}
+
+	for _, b := range s.Branches {
+		if err := b.validate(s.Protection); err != nil {
+			return err
+		}
+	}
+
+	if !containsString(codeowners.Locations, s.CodeOwners.Path) {
+		return fmt.Errorf("codeowners path %q is not a location GitHub reads CODEOWNERS from", s.CodeOwners.Path)
+	}
+
+	for _, rule := range s.CodeOwners.Rules {
+		if rule.Path == "" || len(rule.Owners) == 0 {
+			return errors.New("codeowners rules require a path and at least one owner")
+		}
+	}
+
+	if s.CodeOwners.Suggest.Commits < 1 || s.CodeOwners.Suggest.Commits > 100 {
+		return errors.New("codeowners suggest commits must be between 1 and 100")
+	}
+
+	if _, err := parseCodeOwnersTemplate(s.CodeOwners.Template); err != nil {
+		return fmt.Errorf("codeowners template: %v", err)
+	}
+
+	if err := s.Protection.validate(); err != nil {
+		return err
+	}
+
+	if err := s.Files.validate(); err != nil {
+		return err
+	}
+
+	if err := s.Community.validate(); err != nil {
+		return err
+	}
+
+	return s.PullRequests.validate()
+}
@@ -0,0 +1,138 @@
+package shepherd
+
+import (
+	"reflect"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func testPolicy(t *testing.T, data string) *Policy {
+	t.Helper()
+
+	p := NewPolicy("")
+	if err := yaml.UnmarshalStrict([]byte(data), p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestSettingsFor(t *testing.T) {
+	p := testPolicy(t, `
+org: my-org
+defaults:
+  maintainer: core
+  codeowners:
+    teams: [qa]
+repos:
+  - match: "service-*"
+    branch: main
+    codeowners:
+      path: CODEOWNERS
+  - match: "service-legacy"
+    maintainer: legacy
+    skip: true
+`)
+
+	tests := []struct {
+		repo       string
+		maintainer string
+		branch     string
+		path       string
+		skip       bool
+	}{
+		{repo: "website", maintainer: "core", path: ".github/CODEOWNERS"},
+		{repo: "service-api", maintainer: "core", branch: "main", path: "CODEOWNERS"},
+		{repo: "service-legacy", maintainer: "legacy", branch: "main", path: "CODEOWNERS", skip: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.repo, func(t *testing.T) {
+			s, err := p.SettingsFor(tt.repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if s.Maintainer != tt.maintainer || s.Branch != tt.branch || s.CodeOwners.Path != tt.path || s.Skip != tt.skip {
+				t.Errorf("settings = {maintainer: %q, branch: %q, path: %q, skip: %v}, want {%q, %q, %q, %v}",
+					s.Maintainer, s.Branch, s.CodeOwners.Path, s.Skip, tt.maintainer, tt.branch, tt.path, tt.skip)
+			}
+
+			// keys that are not set by any layer keep their default
+			if !reflect.DeepEqual(s.CodeOwners.Teams, []string{"qa"}) || s.Protection.RequiredApprovingReviews != 1 {
+				t.Errorf("defaults were lost: teams %v, required reviews %d", s.CodeOwners.Teams, s.Protection.RequiredApprovingReviews)
+			}
+		})
+	}
+}
+
+func TestSettingsApply(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		err  bool
+	}{
+		{name: "empty", raw: "{}"},
+		{name: "known keys", raw: "maintainer: core\nprotection:\n  enforce_admins: true\n"},
+		{name: "unknown key", raw: "maintainers: core\n", err: true},
+		{name: "wrong type", raw: "skip: [true]\n", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := map[string]interface{}{}
+			if err := yaml.Unmarshal([]byte(tt.raw), &raw); err != nil {
+				t.Fatal(err)
+			}
+
+			err := defaultSettings().apply(raw)
+			if (err != nil) != tt.err {
+				t.Errorf("apply() error = %v, want error %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSettingsCopy(t *testing.T) {
+	s := defaultSettings()
+	s.CodeOwners.Teams = []string{"qa"}
+	s.Teams.Grants = map[string]string{"security": "pull"}
+
+	c := s.copy()
+
+	// nil and empty lists are the same to yaml, so the settings are compared as yaml
+	want, _ := yaml.Marshal(s)
+	got, _ := yaml.Marshal(c)
+	if string(got) != string(want) {
+		t.Fatalf("copy() = %s, want %s", got, want)
+	}
+
+	// the copy is deep, changing it leaves the original untouched
+	c.CodeOwners.Teams[0] = "docs"
+	c.Teams.Grants["security"] = "push"
+	if s.CodeOwners.Teams[0] != "qa" || s.Teams.Grants["security"] != "pull" {
+		t.Errorf("changing the copy changed the original: %v %v", s.CodeOwners.Teams, s.Teams.Grants)
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		err    bool
+	}{
+		{name: "valid", policy: "org: my-org\ndefaults:\n  maintainer: core\n"},
+		{name: "no org", policy: "defaults:\n  maintainer: core\n", err: true},
+		{name: "no maintainer", policy: "org: my-org\n", err: true},
+		{name: "invalid override", policy: "org: my-org\ndefaults:\n  maintainer: core\nrepos:\n  - match: \"*\"\n    protection:\n      required_approving_reviews: 7\n", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := testPolicy(t, tt.policy).Validate()
+			if (err != nil) != tt.err {
+				t.Errorf("Validate() error = %v, want error %v", err, tt.err)
+			}
+		})
+	}
+}
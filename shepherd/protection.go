@@ -1,60 +1,431 @@
 package shepherd
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
 	"github.com/google/go-github/github"
 )
 
-// DoProtectBranch sets the specfied branch to be protected.
-func (s *ShepardBot) DoProtectBranch(repo *github.Repository, branch *github.Branch) error {
-	owner := *repo.Owner.Login
-	repoName := *repo.Name
+// mediaTypeProtection is required for the required approving review count of branch protection
+const mediaTypeProtection = "application/vnd.github.luke-cage-preview+json"
+
+// protectionState is the protection of a branch, in the form it is sent to the branch protection API.
+// go-github doesn't know about the required approving review count yet, so the API is called directly
+type protectionState struct {
+	RequiredStatusChecks       *github.RequiredStatusChecks      `json:"required_status_checks"`
+	RequiredPullRequestReviews *reviewsState                     `json:"required_pull_request_reviews"`
+	EnforceAdmins              bool                              `json:"enforce_admins"`
+	Restrictions               *github.BranchRestrictionsRequest `json:"restrictions"`
+}
+
+type reviewsState struct {
+	DismissalRestrictions        *github.DismissalRestrictionsRequest `json:"dismissal_restrictions,omitempty"`
+	DismissStaleReviews          bool                                 `json:"dismiss_stale_reviews"`
+	RequireCodeOwnerReviews      bool                                 `json:"require_code_owner_reviews"`
+	RequiredApprovingReviewCount int                                  `json:"required_approving_review_count"`
+}
+
+// protectionResponse is the protection of a branch as it is returned by the branch protection API
+type protectionResponse struct {
+	RequiredStatusChecks       *github.RequiredStatusChecks `json:"required_status_checks"`
+	RequiredPullRequestReviews *struct {
+		DismissalRestrictions        *github.DismissalRestrictions `json:"dismissal_restrictions"`
+		DismissStaleReviews          bool                          `json:"dismiss_stale_reviews"`
+		RequireCodeOwnerReviews      bool                          `json:"require_code_owner_reviews"`
+		RequiredApprovingReviewCount int                           `json:"required_approving_review_count"`
+	} `json:"required_pull_request_reviews"`
+	EnforceAdmins *github.AdminEnforcement   `json:"enforce_admins"`
+	Restrictions  *github.BranchRestrictions `json:"restrictions"`
+}
+
+// Protection modes, merge keeps anything the repo already has that is stricter than the policy while
+// authoritative replaces the protection with the policy
+const (
+	ProtectionMerge         = "merge"
+	ProtectionAuthoritative = "authoritative"
+)
+
+func (p *ProtectionSettings) validate() error {
+	if p.Mode != ProtectionMerge && p.Mode != ProtectionAuthoritative {
+		return fmt.Errorf("protection mode must be %s or %s", ProtectionMerge, ProtectionAuthoritative)
+	}
+
+	if p.RequiredApprovingReviews < 1 || p.RequiredApprovingReviews > 6 {
+		return errors.New("protection required_approving_reviews must be between 1 and 6")
+	}
+
+	return p.StatusChecks.Discover.validate()
+}
 
-	protect := &github.ProtectionRequest{
-		RequiredStatusChecks: &github.RequiredStatusChecks{
-			Strict:   false,
-			Contexts: []string{},
+// desiredProtection returns the protection the protection settings require
+func (s *ShepardBot) desiredProtection(p ProtectionSettings) (*protectionState, error) {
+	desired := &protectionState{
+		RequiredPullRequestReviews: &reviewsState{
+			DismissStaleReviews:          p.DismissStaleReviews,
+			RequireCodeOwnerReviews:      p.RequireCodeOwnerReviews,
+			RequiredApprovingReviewCount: p.RequiredApprovingReviews,
 		},
+		EnforceAdmins: p.EnforceAdmins,
+	}
+
+	if len(p.StatusChecks.Contexts) > 0 || p.StatusChecks.Strict {
+		desired.RequiredStatusChecks = &github.RequiredStatusChecks{
+			Strict:   p.StatusChecks.Strict,
+			Contexts: sortedStrings(p.StatusChecks.Contexts),
+		}
+	}
+
+	if len(p.DismissalRestrictions.Teams) > 0 || len(p.DismissalRestrictions.Users) > 0 {
+		teams, err := s.teamSlugs(p.DismissalRestrictions.Teams)
+		if err != nil {
+			return nil, err
+		}
+
+		desired.RequiredPullRequestReviews.DismissalRestrictions = &github.DismissalRestrictionsRequest{
+			Teams: teams,
+			Users: sortedStrings(p.DismissalRestrictions.Users),
+		}
+	}
+
+	if len(p.PushRestrictions.Teams) > 0 || len(p.PushRestrictions.Users) > 0 {
+		teams, err := s.teamSlugs(p.PushRestrictions.Teams)
+		if err != nil {
+			return nil, err
+		}
+
+		desired.Restrictions = &github.BranchRestrictionsRequest{
+			Teams: teams,
+			Users: sortedStrings(p.PushRestrictions.Users),
+		}
+	}
+
+	return desired, nil
+}
+
+// currentProtection returns the protection of the branch, nil if the branch is not protected
+func (s *ShepardBot) currentProtection(repo *github.Repository, branch *github.Branch) (*protectionState, error) {
+	if !branch.GetProtected() {
+		return nil, nil
+	}
+
+	u := fmt.Sprintf("repos/%v/%v/branches/%v/protection", *repo.Owner.Login, *repo.Name, branch.GetName())
+	req, err := s.gClient.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
 	}
+	req.Header.Set("Accept", mediaTypeProtection)
 
-	_, _, err := s.gClient.Repositories.UpdateBranchProtection(s.ctx, owner, repoName, branch.GetName(), protect)
+	resp := &protectionResponse{}
+	r, err := s.gClient.Do(s.ctx, req, resp)
+	if r != nil && r.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
 
+	if err != nil {
+		return nil, err
+	}
+
+	current := &protectionState{
+		EnforceAdmins: resp.EnforceAdmins != nil && resp.EnforceAdmins.Enabled,
+	}
+
+	if resp.RequiredStatusChecks != nil {
+		current.RequiredStatusChecks = &github.RequiredStatusChecks{
+			Strict:   resp.RequiredStatusChecks.Strict,
+			Contexts: sortedStrings(resp.RequiredStatusChecks.Contexts),
+		}
+	}
+
+	if reviews := resp.RequiredPullRequestReviews; reviews != nil {
+		current.RequiredPullRequestReviews = &reviewsState{
+			DismissStaleReviews:          reviews.DismissStaleReviews,
+			RequireCodeOwnerReviews:      reviews.RequireCodeOwnerReviews,
+			RequiredApprovingReviewCount: reviews.RequiredApprovingReviewCount,
+		}
+
+		if r := reviews.DismissalRestrictions; r != nil && (len(r.Teams) > 0 || len(r.Users) > 0) {
+			current.RequiredPullRequestReviews.DismissalRestrictions = &github.DismissalRestrictionsRequest{
+				Teams: restrictedTeams(r.Teams),
+				Users: restrictedUsers(r.Users),
+			}
+		}
+	}
+
+	if r := resp.Restrictions; r != nil {
+		current.Restrictions = &github.BranchRestrictionsRequest{
+			Teams: restrictedTeams(r.Teams),
+			Users: restrictedUsers(r.Users),
+		}
+	}
+
+	return current, nil
+}
+
+// teamSlugs converts team names of the org to their slugs
+func (s *ShepardBot) teamSlugs(names []string) ([]string, error) {
+	slugs := []string{}
+	for _, name := range names {
+		team, err := s.findTeam(name)
+		if err != nil {
+			return nil, err
+		}
+		slugs = append(slugs, team.GetSlug())
+	}
+
+	sort.Strings(slugs)
+	return slugs, nil
+}
+
+func restrictedTeams(teams []*github.Team) []string {
+	slugs := []string{}
+	for _, team := range teams {
+		slugs = append(slugs, team.GetSlug())
+	}
+
+	sort.Strings(slugs)
+	return slugs
+}
+
+func restrictedUsers(users []*github.User) []string {
+	logins := []string{}
+	for _, user := range users {
+		logins = append(logins, user.GetLogin())
+	}
+
+	sort.Strings(logins)
+	return logins
+}
+
+// sortedStrings returns a sorted copy of the strings, the API requires an empty list rather than null
+func sortedStrings(values []string) []string {
+	sorted := append([]string{}, values...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// targetProtection returns the protection the branch should have, which is the policy of the branch merged into the
+// current protection unless the policy is authoritative
+func (s *ShepardBot) targetProtection(repo *github.Repository, branch *github.Branch, current *protectionState) (*protectionState, error) {
+	protection, err := s.branchProtection(repo, branch.GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	if protection.StatusChecks.Discover.Mode == DiscoverApply {
+		discovered, err := s.DiscoverStatusChecks(repo, branch, protection.StatusChecks.Discover)
+		if err != nil {
+			return nil, err
+		}
+		protection.StatusChecks.Contexts = unionStrings(protection.StatusChecks.Contexts, discovered)
+	}
+
+	desired, err := s.desiredProtection(protection)
+	if err != nil || current == nil || protection.Mode == ProtectionAuthoritative {
+		return desired, err
+	}
+
+	return mergeProtection(current, desired), nil
+}
+
+// mergeProtection merges the desired protection into the current one. Status check contexts are combined,
+// the stricter of every setting wins and restrictions are only replaced when the policy sets them
+func mergeProtection(current *protectionState, desired *protectionState) *protectionState {
+	merged := &protectionState{
+		RequiredStatusChecks: current.RequiredStatusChecks,
+		EnforceAdmins:        current.EnforceAdmins || desired.EnforceAdmins,
+		Restrictions:         current.Restrictions,
+	}
+
+	if d := desired.RequiredStatusChecks; d != nil {
+		if c := current.RequiredStatusChecks; c != nil {
+			merged.RequiredStatusChecks = &github.RequiredStatusChecks{
+				Strict:   c.Strict || d.Strict,
+				Contexts: unionStrings(c.Contexts, d.Contexts),
+			}
+		} else {
+			merged.RequiredStatusChecks = d
+		}
+	}
+
+	merged.RequiredPullRequestReviews = desired.RequiredPullRequestReviews
+	if c, d := current.RequiredPullRequestReviews, desired.RequiredPullRequestReviews; c != nil && d != nil {
+		merged.RequiredPullRequestReviews = &reviewsState{
+			DismissalRestrictions:        c.DismissalRestrictions,
+			DismissStaleReviews:          c.DismissStaleReviews || d.DismissStaleReviews,
+			RequireCodeOwnerReviews:      c.RequireCodeOwnerReviews || d.RequireCodeOwnerReviews,
+			RequiredApprovingReviewCount: c.RequiredApprovingReviewCount,
+		}
+
+		if d.RequiredApprovingReviewCount > c.RequiredApprovingReviewCount {
+			merged.RequiredPullRequestReviews.RequiredApprovingReviewCount = d.RequiredApprovingReviewCount
+		}
+
+		if d.DismissalRestrictions != nil {
+			merged.RequiredPullRequestReviews.DismissalRestrictions = d.DismissalRestrictions
+		}
+	}
+
+	if desired.Restrictions != nil {
+		merged.Restrictions = desired.Restrictions
+	}
+
+	return merged
+}
+
+// unionStrings returns the sorted values that are in either list
+func unionStrings(a, b []string) []string {
+	union := sortedStrings(a)
+	for _, value := range b {
+		if !containsString(union, value) {
+			union = append(union, value)
+		}
+	}
+
+	sort.Strings(union)
+	return union
+}
+
+// DoProtectBranch sets the specfied branch to be protected.
+func (s *ShepardBot) DoProtectBranch(repo *github.Repository, branch *github.Branch) error {
+	current, err := s.currentProtection(repo, branch)
 	if err != nil {
 		return err
 	}
 
-	patch := &github.PullRequestReviewsEnforcementUpdate{
-		RequireCodeOwnerReviews: true,
-		DismissStaleReviews:     github.Bool(true),
+	desired, err := s.targetProtection(repo, branch, current)
+	if err != nil {
+		return err
 	}
 
-	_, _, err = s.gClient.Repositories.UpdatePullRequestReviewEnforcement(
-		s.ctx,
-		owner,
-		repoName,
-		branch.GetName(),
-		patch,
-	)
+	err = s.recordProtection(repo, branch)
+	if err != nil {
+		return err
+	}
 
-	return err
+	return s.putProtection(*repo.Owner.Login, *repo.Name, branch.GetName(), desired)
 }
 
-// CheckProtectionBranch verifies if the the branch is a protected branch and verifies if it has to be verified by CODEOWNERS
-func (s *ShepardBot) CheckProtectionBranch(repo *github.Repository, branch *github.Branch) (bool, error) {
-	// Check if branch is even protected if its not return instantly
-	if !branch.GetProtected() {
-		return false, nil
+// putProtection replaces the protection of the branch
+func (s *ShepardBot) putProtection(owner string, repoName string, branchName string, protection *protectionState) error {
+	u := fmt.Sprintf("repos/%v/%v/branches/%v/protection", owner, repoName, branchName)
+	req, err := s.gClient.NewRequest("PUT", u, protection)
+	if err != nil {
+		return err
 	}
+	req.Header.Set("Accept", mediaTypeProtection)
 
-	reviewEnforcement, _, err := s.gClient.Repositories.GetPullRequestReviewEnforcement(s.ctx,
-		*repo.Owner.Login,
-		*repo.Name,
-		branch.GetName(),
-	)
+	_, err = s.gClient.Do(s.ctx, req, nil)
+	return err
+}
+
+// ProtectionChange is a field of the branch protection that differs from the policy
+type ProtectionChange struct {
+	Field   string
+	Current string
+	Desired string
+}
+
+func (c ProtectionChange) String() string {
+	return fmt.Sprintf("%s: %s → %s", c.Field, c.Current, c.Desired)
+}
+
+// CheckProtectionBranch verifies if the the branch is a protected branch and its protection matches the
+// policy, every field that differs is returned
+func (s *ShepardBot) CheckProtectionBranch(repo *github.Repository, branch *github.Branch) (bool, []ProtectionChange, error) {
+	current, err := s.currentProtection(repo, branch)
+	if err != nil {
+		return false, nil, err
+	}
 
+	desired, err := s.targetProtection(repo, branch, current)
 	if err != nil {
-		return false, err
+		return false, nil, err
+	}
+
+	changes := protectionDiff(current, desired)
+	return len(changes) == 0, changes, nil
+}
+
+// protectionDiff compares every field of the current protection (nil when the branch is not protected)
+// with the desired protection
+func protectionDiff(current *protectionState, desired *protectionState) []ProtectionChange {
+	currentFields := protectionFields(current)
+	desiredFields := protectionFields(desired)
+
+	var changes []ProtectionChange
+	for i := range currentFields {
+		if currentFields[i].value != desiredFields[i].value {
+			changes = append(changes, ProtectionChange{
+				Field:   currentFields[i].name,
+				Current: currentFields[i].value,
+				Desired: desiredFields[i].value,
+			})
+		}
+	}
+	return changes
+}
+
+type protectionField struct {
+	name  string
+	value string
+}
+
+// protectionFields flattens the protection into its fields, always in the same order
+func protectionFields(p *protectionState) []protectionField {
+	protected := p != nil
+	if p == nil {
+		p = &protectionState{}
+	}
+
+	statusChecks := p.RequiredStatusChecks
+	if statusChecks == nil {
+		statusChecks = &github.RequiredStatusChecks{}
+	}
+
+	reviews := p.RequiredPullRequestReviews
+	if reviews == nil {
+		reviews = &reviewsState{}
+	}
+
+	dismissal := reviews.DismissalRestrictions
+	if dismissal == nil {
+		dismissal = &github.DismissalRestrictionsRequest{}
 	}
 
-	// Check if review enforcement is set for codeowners
-	return reviewEnforcement.RequireCodeOwnerReviews, nil
+	push := p.Restrictions
+	if push == nil {
+		push = &github.BranchRestrictionsRequest{}
+	}
+
+	return []protectionField{
+		{"protected", fmt.Sprint(protected)},
+		{"required_status_checks", enabled(p.RequiredStatusChecks != nil)},
+		{"required_status_checks.strict", fmt.Sprint(statusChecks.Strict)},
+		{"required_status_checks.contexts", listValue(statusChecks.Contexts)},
+		{"required_pull_request_reviews", enabled(p.RequiredPullRequestReviews != nil)},
+		{"required_pull_request_reviews.required_approving_review_count", fmt.Sprint(reviews.RequiredApprovingReviewCount)},
+		{"required_pull_request_reviews.require_code_owner_reviews", fmt.Sprint(reviews.RequireCodeOwnerReviews)},
+		{"required_pull_request_reviews.dismiss_stale_reviews", fmt.Sprint(reviews.DismissStaleReviews)},
+		{"required_pull_request_reviews.dismissal_restrictions.teams", listValue(dismissal.Teams)},
+		{"required_pull_request_reviews.dismissal_restrictions.users", listValue(dismissal.Users)},
+		{"enforce_admins", fmt.Sprint(p.EnforceAdmins)},
+		{"restrictions", enabled(p.Restrictions != nil)},
+		{"restrictions.teams", listValue(push.Teams)},
+		{"restrictions.users", listValue(push.Users)},
+	}
+}
+
+func enabled(b bool) string {
+	if b {
+		return "enabled"
+	}
+	return "disabled"
+}
+
+func listValue(values []string) string {
+	return "[" + strings.Join(values, ", ") + "]"
 }
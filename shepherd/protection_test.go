@@ -0,0 +1,152 @@
+package shepherd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestMergeProtection(t *testing.T) {
+	restrictions := &github.BranchRestrictionsRequest{Users: []string{}, Teams: []string{"release"}}
+
+	tests := []struct {
+		name    string
+		current *protectionState
+		desired *protectionState
+		want    *protectionState
+	}{
+		{
+			name:    "nothing to merge",
+			current: &protectionState{},
+			desired: &protectionState{},
+			want:    &protectionState{},
+		},
+		{
+			name: "status check contexts are combined",
+			current: &protectionState{
+				RequiredStatusChecks: &github.RequiredStatusChecks{Strict: true, Contexts: []string{"lint", "build"}},
+			},
+			desired: &protectionState{
+				RequiredStatusChecks: &github.RequiredStatusChecks{Contexts: []string{"test", "build"}},
+			},
+			want: &protectionState{
+				RequiredStatusChecks: &github.RequiredStatusChecks{Strict: true, Contexts: []string{"build", "lint", "test"}},
+			},
+		},
+		{
+			name: "status checks the policy doesn't set are kept",
+			current: &protectionState{
+				RequiredStatusChecks: &github.RequiredStatusChecks{Contexts: []string{"lint"}},
+			},
+			desired: &protectionState{},
+			want: &protectionState{
+				RequiredStatusChecks: &github.RequiredStatusChecks{Contexts: []string{"lint"}},
+			},
+		},
+		{
+			name: "stricter reviews of the repo are kept",
+			current: &protectionState{
+				RequiredPullRequestReviews: &reviewsState{DismissStaleReviews: true, RequiredApprovingReviewCount: 3},
+				EnforceAdmins:              true,
+			},
+			desired: &protectionState{
+				RequiredPullRequestReviews: &reviewsState{RequireCodeOwnerReviews: true, RequiredApprovingReviewCount: 1},
+			},
+			want: &protectionState{
+				RequiredPullRequestReviews: &reviewsState{DismissStaleReviews: true, RequireCodeOwnerReviews: true, RequiredApprovingReviewCount: 3},
+				EnforceAdmins:              true,
+			},
+		},
+		{
+			name: "stricter reviews of the policy win",
+			current: &protectionState{
+				RequiredPullRequestReviews: &reviewsState{RequiredApprovingReviewCount: 1},
+			},
+			desired: &protectionState{
+				RequiredPullRequestReviews: &reviewsState{RequiredApprovingReviewCount: 2},
+				EnforceAdmins:              true,
+			},
+			want: &protectionState{
+				RequiredPullRequestReviews: &reviewsState{RequiredApprovingReviewCount: 2},
+				EnforceAdmins:              true,
+			},
+		},
+		{
+			name:    "restrictions are kept unless the policy sets them",
+			current: &protectionState{Restrictions: restrictions},
+			desired: &protectionState{},
+			want:    &protectionState{Restrictions: restrictions},
+		},
+		{
+			name:    "restrictions of the policy replace those of the repo",
+			current: &protectionState{Restrictions: &github.BranchRestrictionsRequest{Users: []string{"octocat"}, Teams: []string{}}},
+			desired: &protectionState{Restrictions: restrictions},
+			want:    &protectionState{Restrictions: restrictions},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeProtection(tt.current, tt.desired)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeProtection() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProtectionDiff(t *testing.T) {
+	desired := &protectionState{
+		RequiredStatusChecks:       &github.RequiredStatusChecks{Strict: true, Contexts: []string{"build"}},
+		RequiredPullRequestReviews: &reviewsState{RequiredApprovingReviewCount: 1},
+		EnforceAdmins:              true,
+	}
+
+	tests := []struct {
+		name    string
+		current *protectionState
+		want    []ProtectionChange
+	}{
+		{
+			name:    "matching protection",
+			current: desired,
+		},
+		{
+			name:    "unprotected branch",
+			current: nil,
+			want: []ProtectionChange{
+				{Field: "protected", Current: "false", Desired: "true"},
+				{Field: "required_status_checks", Current: "disabled", Desired: "enabled"},
+				{Field: "required_status_checks.strict", Current: "false", Desired: "true"},
+				{Field: "required_status_checks.contexts", Current: "[]", Desired: "[build]"},
+				{Field: "required_pull_request_reviews", Current: "disabled", Desired: "enabled"},
+				{Field: "required_pull_request_reviews.required_approving_review_count", Current: "0", Desired: "1"},
+				{Field: "enforce_admins", Current: "false", Desired: "true"},
+			},
+		},
+		{
+			name: "drifted fields",
+			current: &protectionState{
+				RequiredStatusChecks:       &github.RequiredStatusChecks{Strict: true, Contexts: []string{"build", "lint"}},
+				RequiredPullRequestReviews: &reviewsState{RequiredApprovingReviewCount: 1},
+				Restrictions:               &github.BranchRestrictionsRequest{Users: []string{"octocat"}},
+			},
+			want: []ProtectionChange{
+				{Field: "required_status_checks.contexts", Current: "[build, lint]", Desired: "[build]"},
+				{Field: "enforce_admins", Current: "false", Desired: "true"},
+				{Field: "restrictions", Current: "enabled", Desired: "disabled"},
+				{Field: "restrictions.users", Current: "[octocat]", Desired: "[]"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := protectionDiff(tt.current, desired)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("protectionDiff() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,414 @@
+package shepherd
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/sirupsen/logrus"
+)
+
+// shepherdLabel is added to every PR shepherd opens
+const shepherdLabel = "shepherd"
+
+// Kinds of PR shepherd opens, every kind is committed to its own branch (shepherd/<kind>) so there is
+// only ever one open PR of a kind per repo
+const (
+	PRAddCodeOwners       = "add-codeowners"
+	PRFixCodeOwnersOwners = "fix-codeowners-owners"
+	PREnsureCodeOwners    = "ensure-codeowners"
+	PRSyncFiles           = "sync-files"
+	PRCommunity           = "community-health"
+)
+
+// prTitles are the titles of every kind of PR
+var prTitles = map[string]string{
+	PRAddCodeOwners:       "[AUTOMATED] Adding CODEOWNERS file",
+	PRFixCodeOwnersOwners: "[AUTOMATED] Fixing CODEOWNERS owners",
+	PREnsureCodeOwners:    "[AUTOMATED] Adding maintainers to CODEOWNERS",
+	PRSyncFiles:           "[AUTOMATED] Syncing managed files",
+	PRCommunity:           "[AUTOMATED] Adding community health files",
+}
+
+// changedFile is the desired content of a file in the repo
+type changedFile struct {
+	path    string
+	content []byte
+}
+
+// fileChange describes a change to one or more files that shepherd proposes to a repo through a PR, the
+// files are always written in a single commit
+type fileChange struct {
+	kind  string
+	files []changedFile
+	// details is the kind specific explanation of the change, available to the PR templates
+	details string
+}
+
+// prBranch returns the branch the PR of the kind is committed to
+func prBranch(kind string) string {
+	return "shepherd/" + kind
+}
+
+// FindPR returns the open shepherd PR of the kind, or nil if there is none. PRs are found by their branch,
+// falling back to PRs with the default title that were opened before shepherd used a branch per kind (and
+// before titles could be templated). Only PRs from a branch of the repo itself are considered, a PR from a
+// fork with the same branch name is not shepherd's
+func (s *ShepardBot) FindPR(repo *github.Repository, kind string) (*github.PullRequest, error) {
+	title := prTitles[kind]
+
+	opt := &github.PullRequestListOptions{
+		State:       "open",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	for {
+		pulls, resp, err := s.gClient.PullRequests.List(s.ctx, *repo.Owner.Login, *repo.Name, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pr := range pulls {
+			if pr.GetHead().GetRepo().GetID() != repo.GetID() {
+				continue
+			}
+
+			if pr.GetHead().GetRef() == prBranch(kind) {
+				return pr, nil
+			}
+
+			if pr.GetTitle() == title && strings.Contains(pr.GetHead().GetRef(), "-shepherd-") {
+				return pr, nil
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return nil, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// resetBranch points the branch at the sha, the branch is created if it does not exist
+func (s *ShepardBot) resetBranch(repo *github.Repository, branchName string, sha string) error {
+	ref := &github.Reference{
+		Ref: github.String(fmt.Sprintf("refs/heads/%s", branchName)),
+		Object: &github.GitObject{
+			SHA: github.String(sha),
+		},
+	}
+
+	_, resp, err := s.gClient.Git.GetRef(s.ctx, *repo.Owner.Login, *repo.Name, "heads/"+branchName)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		_, resp, err = s.gClient.Git.CreateRef(s.ctx, *repo.Owner.Login, *repo.Name, ref)
+	} else if err == nil {
+		_, resp, err = s.gClient.Git.UpdateRef(s.ctx, *repo.Owner.Login, *repo.Name, ref, true)
+	}
+
+	if resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusForbidden) {
+		return &ShepardError{resp: resp}
+	}
+
+	return err
+}
+
+// commitFiles creates a single commit on top of the base branch that writes every file of the change, the
+// commit is not referenced by any branch yet
+func (s *ShepardBot) commitFiles(repo *github.Repository, base *github.Branch, change *fileChange, message string) (string, error) {
+	settings, err := s.Settings(repo)
+	if err != nil {
+		return "", err
+	}
+
+	var entries []github.TreeEntry
+	for _, f := range change.files {
+		entries = append(entries, github.TreeEntry{
+			Path:    github.String(f.path),
+			Mode:    github.String("100644"),
+			Type:    github.String("blob"),
+			Content: github.String(string(f.content)),
+		})
+	}
+
+	tree, _, err := s.gClient.Git.CreateTree(s.ctx, *repo.Owner.Login, *repo.Name, base.GetCommit().GetCommit().GetTree().GetSHA(), entries)
+	if err != nil {
+		return "", err
+	}
+
+	commit, _, err := s.gClient.Git.CreateCommit(s.ctx, *repo.Owner.Login, *repo.Name, &github.Commit{
+		Message:   github.String(message),
+		Tree:      tree,
+		Parents:   []github.Commit{{SHA: base.GetCommit().SHA}},
+		Author:    settings.PullRequests.Author.commitAuthor(),
+		Committer: settings.PullRequests.Committer.commitAuthor(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return commit.GetSHA(), nil
+}
+
+// proposeChange ensures there is an open PR against the base branch containing the change. An existing PR
+// is refreshed when the base branch has moved on or the change is different to the one in the PR
+func (s *ShepardBot) proposeChange(repo *github.Repository, base *github.Branch, change *fileChange) (*github.PullRequest, error) {
+	branchName := prBranch(change.kind)
+
+	rendered, err := s.renderPR(repo, base, change)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, err := s.FindPR(repo, change.kind)
+	if err != nil {
+		return nil, err
+	}
+
+	if pr != nil {
+		err = s.refreshPR(repo, base, pr, change, rendered)
+		if err != nil {
+			return pr, err
+		}
+
+		// the refresh may have moved the head of the PR
+		pr, _, err = s.gClient.PullRequests.Get(s.ctx, *repo.Owner.Login, *repo.Name, pr.GetNumber())
+		if err != nil {
+			return pr, err
+		}
+
+		// the review may not have been requested when the PR was opened
+		return pr, s.requestReview(repo, pr)
+	}
+
+	sha, err := s.commitFiles(repo, base, change, rendered.message)
+	if err != nil {
+		return nil, err
+	}
+
+	// a branch left behind by a closed PR is reset, so the PR starts from the current base
+	err = s.resetBranch(repo, branchName, sha)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a PR with the branch created
+	newPR := &github.NewPullRequest{
+		Title:               github.String(rendered.title),
+		MaintainerCanModify: github.Bool(true),
+		Head:                github.String(branchName),
+		Base:                github.String(base.GetName()),
+		Body:                github.String(rendered.body),
+	}
+
+	pr, _, err = s.gClient.PullRequests.Create(s.ctx, *repo.Owner.Login, *repo.Name, newPR)
+	if err != nil {
+		return nil, err
+	}
+
+	return pr, s.setPRMetadata(repo, pr)
+}
+
+// setPRMetadata labels and assigns a new PR, and requests a review from the maintainer team
+func (s *ShepardBot) setPRMetadata(repo *github.Repository, pr *github.PullRequest) error {
+	settings, err := s.Settings(repo)
+	if err != nil {
+		return err
+	}
+
+	labels := append([]string{shepherdLabel}, settings.PullRequests.Labels...)
+	_, _, err = s.gClient.Issues.AddLabelsToIssue(s.ctx, *repo.Owner.Login, *repo.Name, pr.GetNumber(), labels)
+	if err != nil {
+		return err
+	}
+
+	if len(settings.PullRequests.Assignees) > 0 {
+		_, _, err = s.gClient.Issues.AddAssignees(s.ctx, *repo.Owner.Login, *repo.Name, pr.GetNumber(), settings.PullRequests.Assignees)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.requestReview(repo, pr)
+}
+
+// requestReview requests a review of the PR from the maintainer team, unless the team has been requested
+// or someone has reviewed the PR already
+func (s *ShepardBot) requestReview(repo *github.Repository, pr *github.PullRequest) error {
+	settings, err := s.Settings(repo)
+	if err != nil {
+		return err
+	}
+
+	if !settings.PullRequests.RequestReview {
+		return nil
+	}
+
+	team, err := s.maintainerTeam(repo)
+	if err != nil {
+		return err
+	}
+
+	// GitHub rejects review requests from teams without access, the review is requested again on the next
+	// run once the team rule has granted it
+	permission, err := s.teamPermission(repo, team)
+	if err != nil {
+		return err
+	}
+
+	if permission == "" {
+		logrus.Warnf("%s: not requesting a review of PR #%d from %s, the team has no access to the repo yet", repo.GetFullName(), pr.GetNumber(), team.GetSlug())
+		return nil
+	}
+
+	requested, _, err := s.gClient.PullRequests.ListReviewers(s.ctx, *repo.Owner.Login, *repo.Name, pr.GetNumber(), nil)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range requested.Teams {
+		if t.GetID() == team.GetID() {
+			return nil
+		}
+	}
+
+	// a review by the team removes the request, it is not requested again
+	reviews, _, err := s.gClient.PullRequests.ListReviews(s.ctx, *repo.Owner.Login, *repo.Name, pr.GetNumber(), nil)
+	if err != nil {
+		return err
+	}
+
+	if len(reviews) > 0 {
+		return nil
+	}
+
+	// a missing review request is not worth failing the run for, the PR is open and labelled
+	_, _, err = s.gClient.PullRequests.RequestReviewers(s.ctx, *repo.Owner.Login, *repo.Name, pr.GetNumber(), github.ReviewersRequest{
+		TeamReviewers: []string{team.GetSlug()},
+	})
+	if err != nil {
+		logrus.Warnf("%s: unable to request a review of PR #%d from %s: %v", repo.GetFullName(), pr.GetNumber(), team.GetSlug(), err)
+	}
+	return nil
+}
+
+// tokenUser returns the user of the token shepherd runs as
+func (s *ShepardBot) tokenUser() (*github.User, error) {
+	if s.user != nil {
+		return s.user, nil
+	}
+
+	user, _, err := s.gClient.Users.Get(s.ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	s.user = user
+	return user, nil
+}
+
+// ownCommit returns true if shepherd created the commit, i.e. it is committed by the configured committer
+// or, without one, by the user of the token
+func (s *ShepardBot) ownCommit(repo *github.Repository, commit github.RepositoryCommit) (bool, error) {
+	settings, err := s.Settings(repo)
+	if err != nil {
+		return false, err
+	}
+
+	committer := settings.PullRequests.Committer
+	if committer.Name != "" || committer.Email != "" {
+		c := commit.GetCommit().GetCommitter()
+		return c.GetName() == committer.Name && strings.EqualFold(c.GetEmail(), committer.Email), nil
+	}
+
+	user, err := s.tokenUser()
+	if err != nil {
+		return false, err
+	}
+
+	return strings.EqualFold(commit.GetCommitter().GetLogin(), user.GetLogin()), nil
+}
+
+// refreshPR rebuilds the branch of the PR from the current base when the base has moved on, or the
+// content of the PR is no longer the desired content. A PR a maintainer has pushed to is left alone, so
+// their changes are not lost
+func (s *ShepardBot) refreshPR(repo *github.Repository, base *github.Branch, pr *github.PullRequest, change *fileChange, rendered *renderedPR) error {
+	branchName := pr.GetHead().GetRef()
+
+	comparison, _, err := s.gClient.Repositories.CompareCommits(s.ctx, *repo.Owner.Login, *repo.Name, base.Commit.GetSHA(), branchName)
+	if err != nil {
+		return err
+	}
+
+	// shepherd's PRs are a single commit on top of the base
+	own := comparison.GetAheadBy() == 1 && len(comparison.Commits) == 1
+	if own {
+		own, err = s.ownCommit(repo, comparison.Commits[0])
+		if err != nil {
+			return err
+		}
+	}
+
+	if !own {
+		logrus.Warnf("%s: not refreshing PR #%d as its branch has been changed by someone else", repo.GetFullName(), pr.GetNumber())
+		return nil
+	}
+
+	upToDate := comparison.GetBehindBy() == 0
+	for _, f := range change.files {
+		if !upToDate {
+			break
+		}
+
+		content, found, err := s.getFile(repo, f.path, branchName)
+		if err != nil {
+			return err
+		}
+		upToDate = found && bytes.Equal([]byte(content), f.content)
+	}
+
+	if upToDate {
+		return nil
+	}
+
+	logrus.Infof("%s: refreshing PR #%d as %s or its content has changed", repo.GetFullName(), pr.GetNumber(), base.GetName())
+
+	sha, err := s.commitFiles(repo, base, change, rendered.message)
+	if err != nil {
+		return err
+	}
+
+	err = s.resetBranch(repo, branchName, sha)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = s.gClient.PullRequests.Edit(s.ctx, *repo.Owner.Login, *repo.Name, pr.GetNumber(), &github.PullRequest{
+		Title: github.String(rendered.title),
+		Body:  github.String(rendered.body),
+	})
+	return err
+}
+
+// DoClosePR closes the shepherd PR with a comment explaining why and deletes its branch
+func (s *ShepardBot) DoClosePR(repo *github.Repository, pr *github.PullRequest, reason string) error {
+	comment := &github.IssueComment{
+		Body: github.String(fmt.Sprintf("Closing this PR, %s.\n\nThanks,\nShepard Bot", reason)),
+	}
+
+	_, _, err := s.gClient.Issues.CreateComment(s.ctx, *repo.Owner.Login, *repo.Name, pr.GetNumber(), comment)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = s.gClient.PullRequests.Edit(s.ctx, *repo.Owner.Login, *repo.Name, pr.GetNumber(), &github.PullRequest{
+		State: github.String("closed"),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.gClient.Git.DeleteRef(s.ctx, *repo.Owner.Login, *repo.Name, "heads/"+pr.GetHead().GetRef())
+	return err
+}
@@ -0,0 +1,243 @@
+package shepherd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// AutoMergeSettings configures how shepherd lands its own PRs
+type AutoMergeSettings struct {
+	// Enabled merges a shepherd PR once it is approved and its combined status is green
+	Enabled bool `yaml:"enabled"`
+	// Method is the merge method: merge, squash or rebase
+	Method string `yaml:"method"`
+	// NagAfter is how old a PR that cannot be merged has to be before shepherd comments on it, and how
+	// long shepherd waits before commenting again. Zero never comments
+	NagAfter time.Duration `yaml:"nag_after"`
+	// AllowNoStatus merges a PR whose head commit has no status at all, by default shepherd waits for CI
+	// to report on the commit
+	AllowNoStatus bool `yaml:"allow_no_status"`
+}
+
+var mergeMethods = []string{"merge", "squash", "rebase"}
+
+// nagMarker is a hidden marker in the body of nag comments, so shepherd can find its previous nag
+const nagMarker = "<!-- shepherd:nag -->"
+
+func (a *AutoMergeSettings) validate() error {
+	if !containsString(mergeMethods, a.Method) {
+		return fmt.Errorf("pull_requests: unknown auto_merge method %q, expected one of %s", a.Method, strings.Join(mergeMethods, ", "))
+	}
+
+	if a.NagAfter < 0 {
+		return fmt.Errorf("pull_requests: auto_merge nag_after cannot be negative")
+	}
+	return nil
+}
+
+// CheckPRMergeable verifies the shepherd PR is approved, has no outstanding change requests, has no
+// conflicts and its combined status is green. The PR is fetched again and returned, so it is merged at the
+// head commit that was checked. If it cannot be merged the reason is returned
+func (s *ShepardBot) CheckPRMergeable(repo *github.Repository, pr *github.PullRequest) (*github.PullRequest, bool, string, error) {
+	settings, err := s.Settings(repo)
+	if err != nil {
+		return pr, false, "", err
+	}
+
+	// the PR is fetched again, as mergeable is only set when a single PR is requested and the head may
+	// have been refreshed since it was listed
+	pr, _, err = s.gClient.PullRequests.Get(s.ctx, *repo.Owner.Login, *repo.Name, pr.GetNumber())
+	if err != nil {
+		return pr, false, "", err
+	}
+
+	if pr.Mergeable != nil && !pr.GetMergeable() {
+		return pr, false, "it has conflicts", nil
+	}
+
+	approved, err := s.prApproved(repo, pr)
+	if err != nil {
+		return pr, false, "", err
+	}
+
+	if !approved {
+		return pr, false, "its latest commit has not been approved by a maintainer", nil
+	}
+
+	status, _, err := s.gClient.Repositories.GetCombinedStatus(s.ctx, *repo.Owner.Login, *repo.Name, pr.GetHead().GetSHA(), nil)
+	if err != nil {
+		return pr, false, "", err
+	}
+
+	// a commit without any status reports pending, CI may just not have started on a refreshed commit yet
+	if status.GetTotalCount() == 0 {
+		if settings.PullRequests.AutoMerge.AllowNoStatus {
+			return pr, true, "", nil
+		}
+		return pr, false, "no status has been reported on its latest commit", nil
+	}
+
+	if status.GetState() != "success" {
+		return pr, false, fmt.Sprintf("its combined status is %s", status.GetState()), nil
+	}
+
+	return pr, true, "", nil
+}
+
+// canApprove returns true if the reviewer is a member of the maintainer team or can write to the repo,
+// on public repos anyone can leave an approving review
+func (s *ShepardBot) canApprove(repo *github.Repository, login string) (bool, error) {
+	team, err := s.maintainerTeam(repo)
+	if err != nil {
+		return false, err
+	}
+
+	members, err := s.teamMembers(team)
+	if err != nil {
+		return false, err
+	}
+
+	if containsFold(members, login) {
+		return true, nil
+	}
+
+	level, _, err := s.gClient.Repositories.GetPermissionLevel(s.ctx, *repo.Owner.Login, *repo.Name, login)
+	if err != nil {
+		return false, err
+	}
+
+	return level.GetPermission() == "admin" || level.GetPermission() == "write", nil
+}
+
+// prApproved returns true if a reviewer with write access approved the head commit of the PR in their
+// latest review, and no such reviewer has requested changes in their latest review
+func (s *ShepardBot) prApproved(repo *github.Repository, pr *github.PullRequest) (bool, error) {
+	opt := &github.ListOptions{PerPage: 100}
+	latest := map[string]*github.PullRequestReview{}
+
+	for {
+		reviews, resp, err := s.gClient.PullRequests.ListReviews(s.ctx, *repo.Owner.Login, *repo.Name, pr.GetNumber(), opt)
+		if err != nil {
+			return false, err
+		}
+
+		for _, review := range reviews {
+			// comments don't change the verdict of a reviewer
+			if review.GetState() == "COMMENTED" {
+				continue
+			}
+			latest[review.GetUser().GetLogin()] = review
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	approved := false
+	for login, review := range latest {
+		if review.GetState() != "CHANGES_REQUESTED" && review.GetState() != "APPROVED" {
+			continue
+		}
+
+		allowed, err := s.canApprove(repo, login)
+		if err != nil {
+			return false, err
+		}
+
+		if !allowed {
+			continue
+		}
+
+		switch {
+		case review.GetState() == "CHANGES_REQUESTED":
+			return false, nil
+		case review.GetCommitID() == pr.GetHead().GetSHA():
+			// an approval of an earlier commit does not cover a refreshed PR
+			approved = true
+		}
+	}
+
+	return approved, nil
+}
+
+// DoMergePR merges the shepherd PR with the configured merge method and deletes its branch, the PR has to
+// be the one returned by CheckPRMergeable so the merge fails if its head moved since it was checked
+func (s *ShepardBot) DoMergePR(repo *github.Repository, pr *github.PullRequest) error {
+	settings, err := s.Settings(repo)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = s.gClient.PullRequests.Merge(s.ctx, *repo.Owner.Login, *repo.Name, pr.GetNumber(), "", &github.PullRequestOptions{
+		SHA:         pr.GetHead().GetSHA(),
+		MergeMethod: settings.PullRequests.AutoMerge.Method,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.gClient.Git.DeleteRef(s.ctx, *repo.Owner.Login, *repo.Name, "heads/"+pr.GetHead().GetRef())
+	return err
+}
+
+// CheckPRNag returns true if the shepherd PR is older than the configured age and has not been nagged
+// about within that age
+func (s *ShepardBot) CheckPRNag(repo *github.Repository, pr *github.PullRequest) (bool, error) {
+	settings, err := s.Settings(repo)
+	if err != nil {
+		return false, err
+	}
+
+	nagAfter := settings.PullRequests.AutoMerge.NagAfter
+	if nagAfter == 0 || time.Since(pr.GetCreatedAt()) < nagAfter {
+		return false, nil
+	}
+
+	opt := &github.IssueListCommentsOptions{
+		Since:       time.Now().Add(-nagAfter),
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	for {
+		comments, resp, err := s.gClient.Issues.ListComments(s.ctx, *repo.Owner.Login, *repo.Name, pr.GetNumber(), opt)
+		if err != nil {
+			return false, err
+		}
+
+		for _, comment := range comments {
+			if strings.Contains(comment.GetBody(), nagMarker) {
+				return false, nil
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return true, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// DoNagPR comments on the shepherd PR, pinging the maintainer team about why it cannot be merged yet
+func (s *ShepardBot) DoNagPR(repo *github.Repository, pr *github.PullRequest, reason string) error {
+	settings, err := s.Settings(repo)
+	if err != nil {
+		return err
+	}
+
+	maintainer, err := s.ownerRef(settings.Maintainer)
+	if err != nil {
+		return err
+	}
+
+	comment := &github.IssueComment{
+		Body: github.String(fmt.Sprintf("%s\nHi there %s!,\n\nThis PR has been open for %s and cannot be merged as %s. Please take a look, shepherd will merge it as soon as it is approved and its checks pass.\n\nThanks,\nShepard Bot", nagMarker, maintainer, time.Since(pr.GetCreatedAt()).Round(time.Hour), reason)),
+	}
+
+	_, _, err = s.gClient.Issues.CreateComment(s.ctx, *repo.Owner.Login, *repo.Name, pr.GetNumber(), comment)
+	return err
+}
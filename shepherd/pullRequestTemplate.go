@@ -0,0 +1,247 @@
+package shepherd
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/google/go-github/github"
+)
+
+// PullRequestSettings configures the PRs shepherd opens
+type PullRequestSettings struct {
+	// Labels are added to every PR, next to the shepherd label
+	Labels    []string `yaml:"labels"`
+	Assignees []string `yaml:"assignees"`
+	// RequestReview requests a review from the maintainer team
+	RequestReview bool `yaml:"request_review"`
+	// Author and Committer of the commits shepherd makes, defaults to the user of the token
+	Author    Identity `yaml:"author"`
+	Committer Identity `yaml:"committer"`
+	// Templates override the title, body and commit message of a kind of PR
+	Templates map[string]PRTemplate `yaml:"templates"`
+	// AutoMerge lands the PRs once they are approved and green
+	AutoMerge AutoMergeSettings `yaml:"auto_merge"`
+}
+
+// Identity is a git author or committer
+type Identity struct {
+	Name  string `yaml:"name"`
+	Email string `yaml:"email"`
+}
+
+// PRTemplate holds the text/templates used for a kind of PR, empty templates fall back to the default
+type PRTemplate struct {
+	Title   string `yaml:"title"`
+	Body    string `yaml:"body"`
+	Message string `yaml:"message"`
+}
+
+// prData is the data available to PR templates
+type prData struct {
+	Org        string
+	Repo       string
+	FullName   string
+	Branch     string
+	Maintainer string
+	// Path is the first file of the change, Paths are all of them
+	Path  string
+	Paths []string
+	// Details is the kind specific explanation of the change, e.g. the owners that are removed
+	Details string
+}
+
+const prFooter = `This PR is automatically created by [shepherd](https://github.com/srizzling/shepherd)
+
+Thanks,
+Shepard Bot`
+
+// defaultPRTemplates are the templates used for every kind of PR unless the policy overrides them
+var defaultPRTemplates = map[string]PRTemplate{
+	PRAddCodeOwners: {
+		Title: prTitles[PRAddCodeOwners],
+		Body: `Hi there {{ .Maintainer }}!,
+
+I'm your helpful shepherd and I've found that you are missing an important CODEOWNERS file which is mandated to be included for repos within this org (this ensures that the maintainers are pinged to review PR as they come in).
+
+{{ with .Details }}{{ . }}
+
+{{ end }}` + prFooter,
+		Message: "Adding CODEOWNERS file",
+	},
+	PRFixCodeOwnersOwners: {
+		Title: prTitles[PRFixCodeOwnersOwners],
+		Body: `Hi there {{ .Maintainer }}!,
+
+I'm your helpful shepherd and I've found owners in the CODEOWNERS file that can no longer approve changes to this repo, so their rules are not being enforced:
+
+{{ .Details }}
+
+This PR removes them (rules left without an owner are assigned to {{ .Maintainer }}).
+
+` + prFooter,
+		Message: "Removing broken CODEOWNERS owners",
+	},
+	PREnsureCodeOwners: {
+		Title: prTitles[PREnsureCodeOwners],
+		Body: `Hi there {{ .Maintainer }}!,
+
+I'm your helpful shepherd and I've found that the maintainers of this repo are not owners of every file in the CODEOWNERS file, which is mandated for repos within this org (this ensures that the maintainers are pinged to review PR as they come in).
+
+This PR adds {{ .Maintainer }} to the catch-all ` + "`*`" + ` rule, every other rule is left untouched.
+
+` + prFooter,
+		Message: "Adding maintainers to CODEOWNERS",
+	},
+	PRSyncFiles: {
+		Title: prTitles[PRSyncFiles],
+		Body: `Hi there {{ .Maintainer }}!,
+
+I'm your helpful shepherd and I've found that files managed for every repo within this org are missing or out of date:
+
+{{ .Details }}
+
+This PR brings them in line with the org's templates.
+
+` + prFooter,
+		Message: "Syncing managed files",
+	},
+	PRCommunity: {
+		Title: prTitles[PRCommunity],
+		Body: `Hi there {{ .Maintainer }}!,
+
+I'm your helpful shepherd and I've found that the community profile of this repo is missing items that are mandated for repos within this org. This PR adds templates for them:
+
+{{ .Details }}
+
+Please fill them in for this repo before merging.
+
+` + prFooter,
+		Message: "Adding community health files",
+	},
+}
+
+// prTemplate returns the templates of the kind, falling back to the default for any template that is not set
+func (p *PullRequestSettings) prTemplate(kind string) PRTemplate {
+	tmpl := defaultPRTemplates[kind]
+	custom := p.Templates[kind]
+
+	if custom.Title != "" {
+		tmpl.Title = custom.Title
+	}
+	if custom.Body != "" {
+		tmpl.Body = custom.Body
+	}
+	if custom.Message != "" {
+		tmpl.Message = custom.Message
+	}
+	return tmpl
+}
+
+func (p *PullRequestSettings) validate() error {
+	if err := p.AutoMerge.validate(); err != nil {
+		return err
+	}
+
+	if err := p.Author.validate("author"); err != nil {
+		return err
+	}
+
+	if err := p.Committer.validate("committer"); err != nil {
+		return err
+	}
+
+	for kind, tmpl := range p.Templates {
+		if _, ok := defaultPRTemplates[kind]; !ok {
+			return fmt.Errorf("pull_requests: unknown kind of PR %q", kind)
+		}
+
+		for _, text := range []string{tmpl.Title, tmpl.Body, tmpl.Message} {
+			if _, err := template.New(kind).Option("missingkey=error").Parse(text); err != nil {
+				return fmt.Errorf("pull_requests: %s: %v", kind, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validate ensures the identity is either unset or complete, GitHub rejects a commit with only one of them
+func (i Identity) validate(field string) error {
+	if (i.Name == "") != (i.Email == "") {
+		return fmt.Errorf("pull_requests: %s requires both a name and an email", field)
+	}
+	return nil
+}
+
+// commitAuthor returns the identity as a git author, nil means the user of the token is used
+func (i Identity) commitAuthor() *github.CommitAuthor {
+	if i.Name == "" && i.Email == "" {
+		return nil
+	}
+	return &github.CommitAuthor{
+		Name:  github.String(i.Name),
+		Email: github.String(i.Email),
+	}
+}
+
+// renderedPR is the title, body and commit message of a PR after its templates have been rendered
+type renderedPR struct {
+	title   string
+	body    string
+	message string
+}
+
+// renderPR renders the templates of the kind of PR for the change
+func (s *ShepardBot) renderPR(repo *github.Repository, base *github.Branch, change *fileChange) (*renderedPR, error) {
+	settings, err := s.Settings(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	maintainer, err := s.ownerRef(settings.Maintainer)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, f := range change.files {
+		paths = append(paths, f.path)
+	}
+
+	data := prData{
+		Org:        s.org.GetLogin(),
+		Repo:       repo.GetName(),
+		FullName:   repo.GetFullName(),
+		Branch:     base.GetName(),
+		Maintainer: maintainer,
+		Path:       paths[0],
+		Paths:      paths,
+		Details:    change.details,
+	}
+
+	tmpl := settings.PullRequests.prTemplate(change.kind)
+	rendered := &renderedPR{}
+
+	for _, t := range []struct {
+		text string
+		out  *string
+	}{
+		{tmpl.Title, &rendered.title},
+		{tmpl.Body, &rendered.body},
+		{tmpl.Message, &rendered.message},
+	} {
+		parsed, err := template.New(change.kind).Option("missingkey=error").Parse(t.text)
+		if err != nil {
+			return nil, err
+		}
+
+		buf := new(bytes.Buffer)
+		err = parsed.Execute(buf, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: unable to render %s PR: %v", repo.GetFullName(), change.kind, err)
+		}
+		*t.out = buf.String()
+	}
+
+	return rendered, nil
+}
@@ -0,0 +1,32 @@
+package shepherd
+
+import "testing"
+
+func TestPullRequestSettingsValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings PullRequestSettings
+		err      bool
+	}{
+		{name: "defaults", settings: PullRequestSettings{}},
+		{name: "author", settings: PullRequestSettings{Author: Identity{Name: "shepherd", Email: "shepherd@example.com"}}},
+		{name: "author without email", settings: PullRequestSettings{Author: Identity{Name: "shepherd"}}, err: true},
+		{name: "committer without name", settings: PullRequestSettings{Committer: Identity{Email: "shepherd@example.com"}}, err: true},
+		{name: "template", settings: PullRequestSettings{Templates: map[string]PRTemplate{PRSyncFiles: {Title: "Sync {{ .Repo }}"}}}},
+		{name: "unknown kind", settings: PullRequestSettings{Templates: map[string]PRTemplate{"unknown": {Title: "title"}}}, err: true},
+		{name: "invalid template", settings: PullRequestSettings{Templates: map[string]PRTemplate{PRSyncFiles: {Title: "{{ .Repo "}}}, err: true},
+		{name: "unknown merge method", settings: PullRequestSettings{AutoMerge: AutoMergeSettings{Method: "fast-forward"}}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.settings.AutoMerge.Method == "" {
+				tt.settings.AutoMerge.Method = "merge"
+			}
+
+			if err := tt.settings.validate(); (err != nil) != tt.err {
+				t.Errorf("validate() error = %v, want error %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package shepherd
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// RepoConfigPolicy controls how the in-repo configuration file is read and which keys a repo may override
+type RepoConfigPolicy struct {
+	Path  string   `yaml:"path"`
+	Allow []string `yaml:"allow"`
+}
+
+// getFile returns the content of a file in the repo at the ref, found is false if the file does not exist
+func (s *ShepardBot) getFile(repo *github.Repository, filePath string, ref string) (string, bool, error) {
+	opt := &github.RepositoryContentGetOptions{
+		Ref: ref,
+	}
+
+	file, _, resp, err := s.gClient.Repositories.GetContents(
+		s.ctx,
+		*repo.Owner.Login,
+		*repo.Name,
+		filePath,
+		opt,
+	)
+
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return "", false, nil
+	}
+
+	if err != nil {
+		return "", false, err
+	}
+
+	// a directory with the same name is not the file we are looking for
+	if file == nil {
+		return "", false, nil
+	}
+
+	content, err := file.GetContent()
+	if err != nil {
+		return "", false, err
+	}
+
+	return content, true, nil
+}
+
+// repoConfig reads the in-repo configuration file from the default branch of the repo and returns
+// the keys the policy allows the repo to override
+func (s *ShepardBot) repoConfig(repo *github.Repository) (map[string]interface{}, error) {
+	content, found, err := s.getFile(repo, s.policy.RepoConfig.Path, repo.GetDefaultBranch())
+	if err != nil || !found {
+		return nil, err
+	}
+
+	// a typo of a repo owner should not stop the run for the whole org, the central settings are used
+	raw := map[string]interface{}{}
+	err = yaml.Unmarshal([]byte(content), &raw)
+	if err != nil {
+		logrus.Warnf("%s: unable to parse %s, using the settings of the policy: %v", repo.GetFullName(), s.policy.RepoConfig.Path, err)
+		return nil, nil
+	}
+
+	allowed, denied := filterKeys(raw, s.policy.RepoConfig.Allow, "")
+	for _, key := range denied {
+		logrus.Warnf("%s: %s sets %s which repos are not permitted to override, ignoring", repo.GetFullName(), s.policy.RepoConfig.Path, key)
+	}
+
+	return allowed, nil
+}
+
+// filterKeys splits the raw settings into the keys that are allowed and the (dotted) keys that are not,
+// an allowed key such as "protection" permits all of its nested keys while "protection.dismiss_stale_reviews"
+// only permits that nested key
+func filterKeys(raw map[string]interface{}, allow []string, prefix string) (map[string]interface{}, []string) {
+	allowed := map[string]interface{}{}
+	var denied []string
+
+	for key, value := range raw {
+		fullKey := prefix + key
+
+		if containsString(allow, fullKey) {
+			allowed[key] = value
+			continue
+		}
+
+		nested, ok := toStringMap(value)
+		if !ok || !hasPrefixString(allow, fullKey+".") {
+			denied = append(denied, fullKey)
+			continue
+		}
+
+		nestedAllowed, nestedDenied := filterKeys(nested, allow, fullKey+".")
+		if len(nestedAllowed) > 0 {
+			allowed[key] = nestedAllowed
+		}
+		denied = append(denied, nestedDenied...)
+	}
+
+	return allowed, denied
+}
+
+// toStringMap converts a yaml mapping into a map keyed by strings
+func toStringMap(value interface{}) (map[string]interface{}, bool) {
+	m, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	out := map[string]interface{}{}
+	for k, v := range m {
+		out[fmt.Sprint(k)] = v
+	}
+	return out, true
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPrefixString(list []string, prefix string) bool {
+	for _, item := range list {
+		if strings.HasPrefix(item, prefix) {
+			return true
+		}
+	}
+	return false
+}
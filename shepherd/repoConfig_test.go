@@ -0,0 +1,77 @@
+package shepherd
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestFilterKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		allow   []string
+		allowed string
+		denied  []string
+	}{
+		{
+			name:    "nothing allowed",
+			config:  "skip: true\nbranch: develop\n",
+			allowed: "{}\n",
+			denied:  []string{"branch", "skip"},
+		},
+		{
+			name:    "top level key",
+			config:  "skip: true\nbranch: develop\n",
+			allow:   []string{"skip"},
+			allowed: "skip: true\n",
+			denied:  []string{"branch"},
+		},
+		{
+			name:    "top level key permits nested keys",
+			config:  "codeowners:\n  teams: [docs]\n  path: CODEOWNERS\n",
+			allow:   []string{"codeowners"},
+			allowed: "codeowners:\n  path: CODEOWNERS\n  teams:\n  - docs\n",
+		},
+		{
+			name:    "nested key",
+			config:  "codeowners:\n  teams: [docs]\n  path: CODEOWNERS\n",
+			allow:   []string{"codeowners.teams"},
+			allowed: "codeowners:\n  teams:\n  - docs\n",
+			denied:  []string{"codeowners.path"},
+		},
+		{
+			name:    "nested key of a scalar",
+			config:  "codeowners: none\n",
+			allow:   []string{"codeowners.teams"},
+			allowed: "{}\n",
+			denied:  []string{"codeowners"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := map[string]interface{}{}
+			if err := yaml.Unmarshal([]byte(tt.config), &raw); err != nil {
+				t.Fatal(err)
+			}
+
+			allowed, denied := filterKeys(raw, tt.allow, "")
+
+			data, err := yaml.Marshal(allowed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.allowed {
+				t.Errorf("allowed = %q, want %q", data, tt.allowed)
+			}
+
+			sort.Strings(denied)
+			if !reflect.DeepEqual(denied, tt.denied) {
+				t.Errorf("denied = %v, want %v", denied, tt.denied)
+			}
+		})
+	}
+}
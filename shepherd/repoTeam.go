@@ -1,43 +1,87 @@
 package shepherd
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/google/go-github/github"
 )
 
-// CheckTeamRepoManagement verifies if the team is an admin of the project
-func (s *ShepardBot) CheckTeamRepoManagement(repo *github.Repository) (bool, error) {
+// teamPermissions are the permissions a team can have on a repo, from the highest to the lowest
+var teamPermissions = []string{"admin", "maintain", "push", "triage", "pull"}
 
-	_, response, err := s.gClient.Organizations.IsTeamRepo(
-		s.ctx,
-		*s.maintainerTeam.ID,
-		*repo.Owner.Login,
-		*repo.Name,
-	)
+func validatePermission(permission string) error {
+	if !containsString(teamPermissions, permission) {
+		return fmt.Errorf("unknown permission %q, expected one of %s", permission, strings.Join(teamPermissions, ", "))
+	}
+	return nil
+}
+
+// teamPermission returns the highest permission the team has on the repo, or an empty string if the team
+// has no access
+func (s *ShepardBot) teamPermission(repo *github.Repository, team *github.Team) (string, error) {
+	teamRepo, resp, err := s.gClient.Organizations.IsTeamRepo(s.ctx, team.GetID(), *repo.Owner.Login, *repo.Name)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
 
-	// not managed
-	if response.StatusCode == http.StatusNotFound {
-		return false, nil
+	if err != nil {
+		return "", err
 	}
 
-	if response.StatusCode == http.StatusOK {
-		return true, nil
+	permissions := teamRepo.GetPermissions()
+	for _, permission := range teamPermissions {
+		if permissions[permission] {
+			return permission, nil
+		}
 	}
+	return "", nil
+}
 
+// CheckTeamRepoManagement verifies if the maintainer team has exactly the permission required by the policy,
+// the permission the team currently has is returned (empty if the team has no access)
+func (s *ShepardBot) CheckTeamRepoManagement(repo *github.Repository) (bool, string, error) {
+	settings, err := s.Settings(repo)
 	if err != nil {
-		return false, err
+		return false, "", err
 	}
 
-	return false, nil
+	team, err := s.maintainerTeam(repo)
+	if err != nil {
+		return false, "", err
+	}
+
+	permission, err := s.teamPermission(repo, team)
+	if err != nil {
+		return false, "", err
+	}
+
+	return permission == settings.MaintainerPermission, permission, nil
 }
 
-// DoTeamRepoManagement sets the team as an admin of the repo
+// DoTeamRepoManagement grants the maintainer team the permission required by the policy, upgrading or
+// downgrading the permission it has
 func (s *ShepardBot) DoTeamRepoManagement(repo *github.Repository) error {
+	settings, err := s.Settings(repo)
+	if err != nil {
+		return err
+	}
+
+	team, err := s.maintainerTeam(repo)
+	if err != nil {
+		return err
+	}
+
+	err = s.recordTeam(repo, team)
+	if err != nil {
+		return err
+	}
+
 	opt := &github.OrganizationAddTeamRepoOptions{
-		Permission: "admin",
+		Permission: settings.MaintainerPermission,
 	}
 
-	_, err := s.gClient.Organizations.AddTeamRepo(s.ctx, *s.maintainerTeam.ID, *repo.Owner.Login, *repo.Name, opt)
+	_, err = s.gClient.Organizations.AddTeamRepo(s.ctx, team.GetID(), *repo.Owner.Login, *repo.Name, opt)
 	return err
 }
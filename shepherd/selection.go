@@ -0,0 +1,132 @@
+package shepherd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// Selection filters the repos of the org that shepherd will herd
+type Selection struct {
+	// Repo targets a single repository by name, all other filters still apply
+	Repo string `yaml:"repo"`
+	// Include and Exclude are regexes matched against the repo name, a repo must match at least one
+	// include (if any are provided) and none of the excludes
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+	// Topics selects repos that have at least one of the topics
+	Topics []string `yaml:"topics"`
+	// Visibility can be one of all, public or private
+	Visibility string `yaml:"visibility"`
+	// Forks and Archived repos are skipped unless enabled, GitHub refuses most changes to archived repos
+	Forks    bool `yaml:"forks"`
+	Archived bool `yaml:"archived"`
+
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+func (sel *Selection) compile() error {
+	switch sel.Visibility {
+	case "", "all", "public", "private":
+	default:
+		return fmt.Errorf("select: unknown visibility %q, expected one of all, public, private", sel.Visibility)
+	}
+
+	var err error
+	sel.include, err = compileAll(sel.Include)
+	if err != nil {
+		return fmt.Errorf("select: include: %v", err)
+	}
+
+	sel.exclude, err = compileAll(sel.Exclude)
+	if err != nil {
+		return fmt.Errorf("select: exclude: %v", err)
+	}
+
+	return nil
+}
+
+func compileAll(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// listType returns the repo type to request from the API, filtering server side where possible
+func (sel *Selection) listType() string {
+	if sel.Visibility == "public" || sel.Visibility == "private" {
+		return sel.Visibility
+	}
+	return "all"
+}
+
+// Matches returns whether the repo is selected, and if it is not the reason why it was skipped
+func (sel *Selection) Matches(repo *github.Repository) (bool, string) {
+	name := repo.GetName()
+
+	if sel.Repo != "" && !strings.EqualFold(sel.Repo, name) && !strings.EqualFold(sel.Repo, repo.GetFullName()) {
+		return false, "not the targeted repo"
+	}
+
+	if repo.GetArchived() && !sel.Archived {
+		return false, "repo is archived"
+	}
+
+	if repo.GetFork() && !sel.Forks {
+		return false, "repo is a fork"
+	}
+
+	switch sel.Visibility {
+	case "public":
+		if repo.GetPrivate() {
+			return false, "repo is private"
+		}
+	case "private":
+		if !repo.GetPrivate() {
+			return false, "repo is public"
+		}
+	}
+
+	if len(sel.include) > 0 && !matchesAny(sel.include, name) {
+		return false, "name does not match an include filter"
+	}
+
+	if matchesAny(sel.exclude, name) {
+		return false, "name matches an exclude filter"
+	}
+
+	if len(sel.Topics) > 0 && !hasAnyTopic(repo, sel.Topics) {
+		return false, "repo has none of the selected topics"
+	}
+
+	return true, ""
+}
+
+func matchesAny(res []*regexp.Regexp, value string) bool {
+	for _, re := range res {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnyTopic(repo *github.Repository, topics []string) bool {
+	for _, topic := range repo.Topics {
+		for _, wanted := range topics {
+			if strings.EqualFold(topic, wanted) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package shepherd
+
+import (
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestSelectionMatches(t *testing.T) {
+	repo := func(name string, fork, archived, private bool, topics ...string) *github.Repository {
+		return &github.Repository{
+			Name:     github.String(name),
+			FullName: github.String("my-org/" + name),
+			Fork:     github.Bool(fork),
+			Archived: github.Bool(archived),
+			Private:  github.Bool(private),
+			Topics:   topics,
+		}
+	}
+
+	tests := []struct {
+		name   string
+		sel    Selection
+		repo   *github.Repository
+		want   bool
+		reason string
+	}{
+		{name: "everything", repo: repo("api", false, false, false), want: true},
+		{name: "targeted repo", sel: Selection{Repo: "API"}, repo: repo("api", false, false, false), want: true},
+		{name: "targeted by full name", sel: Selection{Repo: "my-org/api"}, repo: repo("api", false, false, false), want: true},
+		{name: "other repo", sel: Selection{Repo: "web"}, repo: repo("api", false, false, false), reason: "not the targeted repo"},
+		{name: "archived", repo: repo("api", false, true, false), reason: "repo is archived"},
+		{name: "archived selected", sel: Selection{Archived: true}, repo: repo("api", false, true, false), want: true},
+		{name: "fork", repo: repo("api", true, false, false), reason: "repo is a fork"},
+		{name: "fork selected", sel: Selection{Forks: true}, repo: repo("api", true, false, false), want: true},
+		{name: "public only", sel: Selection{Visibility: "public"}, repo: repo("api", false, false, true), reason: "repo is private"},
+		{name: "private only", sel: Selection{Visibility: "private"}, repo: repo("api", false, false, false), reason: "repo is public"},
+		{name: "included", sel: Selection{Include: []string{"^service-"}}, repo: repo("service-api", false, false, false), want: true},
+		{name: "not included", sel: Selection{Include: []string{"^service-"}}, repo: repo("api", false, false, false), reason: "name does not match an include filter"},
+		{name: "excluded", sel: Selection{Include: []string{"^service-"}, Exclude: []string{"-legacy$"}}, repo: repo("service-legacy", false, false, false), reason: "name matches an exclude filter"},
+		{name: "topic", sel: Selection{Topics: []string{"Go"}}, repo: repo("api", false, false, false, "docker", "go"), want: true},
+		{name: "no topic", sel: Selection{Topics: []string{"go"}}, repo: repo("api", false, false, false, "docker"), reason: "repo has none of the selected topics"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.sel.compile(); err != nil {
+				t.Fatal(err)
+			}
+
+			got, reason := tt.sel.Matches(tt.repo)
+			if got != tt.want || reason != tt.reason {
+				t.Errorf("Matches() = %v, %q, want %v, %q", got, reason, tt.want, tt.reason)
+			}
+		})
+	}
+}
+
+func TestSelectionCompile(t *testing.T) {
+	tests := []struct {
+		name string
+		sel  Selection
+		err  bool
+	}{
+		{name: "empty"},
+		{name: "visibility", sel: Selection{Visibility: "private"}},
+		{name: "unknown visibility", sel: Selection{Visibility: "internal"}, err: true},
+		{name: "invalid include", sel: Selection{Include: []string{"("}}, err: true},
+		{name: "invalid exclude", sel: Selection{Exclude: []string{"["}}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.sel.compile(); (err != nil) != tt.err {
+				t.Errorf("compile() error = %v, want error %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -5,17 +5,27 @@ import (
 	"context"
 	"fmt"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/google/go-github/github"
+	"github.com/sirupsen/logrus"
 	"golang.org/x/oauth2"
 )
 
 // ShepardBot is the main bot object that gets created
 type ShepardBot struct {
-	gClient        *github.Client
-	ctx            context.Context
-	maintainerTeam *github.Team
-	org            *github.Organization
+	gClient  *github.Client
+	ctx      context.Context
+	org      *github.Organization
+	teams    []*github.Team
+	members  map[int64][]string
+	outside  map[string]bool
+	user     *github.User
+	base     *string
+	policy   *Policy
+	settings map[string]*Settings
+	snapshot *Snapshot
 }
 
 // ShepardError is a generic error container for reporting errors/http status code errors from the Github API
@@ -31,7 +41,8 @@ func (e *ShepardError) Error() string {
 }
 
 // NewBot creates a new ShepardBot based off the baseURL(provide empty string if you want to default to basic github)
-func NewBot(baseURL string, token string, maintainerTeamName string, orgName string) (*ShepardBot, error) {
+// the policy should be validated before the bot is created
+func NewBot(baseURL string, token string, policy *Policy) (*ShepardBot, error) {
 	// initialize a new github client
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: token},
@@ -51,28 +62,106 @@ func NewBot(baseURL string, token string, maintainerTeamName string, orgName str
 	}
 
 	bot := &ShepardBot{
-		gClient: client,
-		ctx:     ctx,
+		gClient:  client,
+		ctx:      ctx,
+		members:  map[int64][]string{},
+		policy:   policy,
+		settings: map[string]*Settings{},
 	}
 
 	// set github org to bot
-	err := bot.setOrg(orgName)
+	err := bot.setOrg(policy.Org)
 	if err != nil {
 		return nil, err
 	}
 
-	// set maintainer team to org
-	err = bot.setMaintainerTeam(maintainerTeamName)
+	bot.snapshot = &Snapshot{
+		RunID:   newRunID(),
+		Org:     bot.org.GetLogin(),
+		Created: time.Now(),
+	}
+
+	// cache the teams of the org, so maintainer teams can be looked up for every repo
+	bot.teams, err = bot.retreiveTeams(bot.org.GetLogin())
+	if err != nil {
+		return nil, err
+	}
+
+	// ensure every team of the policy exists before any repo is touched
+	teams, err := policy.teams()
 	if err != nil {
 		return nil, err
 	}
 
+	for _, team := range teams {
+		_, err = bot.findTeam(team)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return bot, nil
 }
 
-// RetreiveRepos returns a list of repos within the organization
+// Settings returns the effective policy settings for the repo, including any overrides the repo
+// has made in its own configuration file
+func (s *ShepardBot) Settings(repo *github.Repository) (*Settings, error) {
+	if settings, ok := s.settings[repo.GetFullName()]; ok {
+		return settings, nil
+	}
+
+	settings, err := s.policy.SettingsFor(repo.GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	overrides, err := s.repoConfig(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(overrides) > 0 {
+		merged := settings.copy()
+		err = merged.apply(overrides)
+		if err == nil {
+			err = merged.validate()
+		}
+		if err == nil {
+			err = s.checkTeams(merged)
+		}
+
+		// an invalid in-repo configuration only affects its own repo, which keeps the settings of the policy
+		if err != nil {
+			logrus.Warnf("%s: ignoring %s, using the settings of the policy: %v", repo.GetFullName(), s.policy.RepoConfig.Path, err)
+		} else {
+			settings = merged
+		}
+	}
+
+	if settings.Branch == "" {
+		settings.Branch = repo.GetDefaultBranch()
+	}
+
+	s.settings[repo.GetFullName()] = settings
+	return settings, nil
+}
+
+// RetreiveRepos returns a list of repos within the organization that are selected by the policy
 func (s *ShepardBot) RetreiveRepos() ([]*github.Repository, error) {
+	sel := &s.policy.Select
+
+	// a single targeted repo does not require listing the whole org
+	if sel.Repo != "" {
+		name := strings.TrimPrefix(sel.Repo, s.org.GetLogin()+"/")
+		repo, _, err := s.gClient.Repositories.Get(s.ctx, s.org.GetLogin(), name)
+		if err != nil {
+			return nil, err
+		}
+		return s.selectRepos([]*github.Repository{repo}), nil
+	}
+
 	opt := &github.RepositoryListByOrgOptions{
+		Type:        sel.listType(),
 		ListOptions: github.ListOptions{PerPage: 10},
 	}
 
@@ -82,7 +171,7 @@ func (s *ShepardBot) RetreiveRepos() ([]*github.Repository, error) {
 		if err != nil {
 			return nil, err
 		}
-		allRepos = append(allRepos, repos...)
+		allRepos = append(allRepos, s.selectRepos(repos)...)
 		if resp.NextPage == 0 {
 			break
 		}
@@ -92,6 +181,20 @@ func (s *ShepardBot) RetreiveRepos() ([]*github.Repository, error) {
 	return allRepos, nil
 }
 
+// selectRepos filters out the repos that are not selected by the policy
+func (s *ShepardBot) selectRepos(repos []*github.Repository) []*github.Repository {
+	var selected []*github.Repository
+	for _, repo := range repos {
+		ok, reason := s.policy.Select.Matches(repo)
+		if !ok {
+			logrus.Debugf("%s: skipped, %s", repo.GetFullName(), reason)
+			continue
+		}
+		selected = append(selected, repo)
+	}
+	return selected
+}
+
 // GetBranch function return a branch obj depending on the name provided
 func (s *ShepardBot) GetBranch(repo *github.Repository, branchName string) (*github.Branch, error) {
 	branch, _, err := s.gClient.Repositories.GetBranch(s.ctx, *repo.Owner.Login, *repo.Name, branchName)
@@ -0,0 +1,200 @@
+package shepherd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// Kinds of state captured in a snapshot
+const (
+	SnapshotProtection = "protection"
+	SnapshotTeam       = "team"
+	// SnapshotCollaborator is the permission of a collaborator removed from the repo
+	SnapshotCollaborator = "collaborator"
+)
+
+// Snapshot is the state of every setting a run of shepherd changed, as it was before the run changed it
+type Snapshot struct {
+	RunID   string          `json:"run_id"`
+	Org     string          `json:"org"`
+	Created time.Time       `json:"created"`
+	Entries []SnapshotEntry `json:"entries"`
+}
+
+// SnapshotEntry is the prior state of a single setting of a repo
+type SnapshotEntry struct {
+	Kind string `json:"kind"`
+	Repo string `json:"repo"`
+
+	// Branch and Protection are set for protection entries, a nil protection means the branch was not protected
+	Branch     string           `json:"branch,omitempty"`
+	Protection *protectionState `json:"protection,omitempty"`
+
+	// TeamID, Team and Permission are set for team entries, an empty permission means the team had no access
+	TeamID     int64  `json:"team_id,omitempty"`
+	Team       string `json:"team,omitempty"`
+	Permission string `json:"permission,omitempty"`
+
+	// User and Permission are set for collaborator entries
+	User string `json:"user,omitempty"`
+}
+
+// key identifies the setting of the entry, only the first state of a setting is kept
+func (e SnapshotEntry) key() string {
+	return strings.Join([]string{e.Kind, e.Repo, e.Branch, fmt.Sprint(e.TeamID), e.User}, "|")
+}
+
+// String describes the setting of the entry
+func (e SnapshotEntry) String() string {
+	switch e.Kind {
+	case SnapshotProtection:
+		return fmt.Sprintf("%s: protection of %s", e.Repo, e.Branch)
+	case SnapshotTeam:
+		return fmt.Sprintf("%s: permission of team %s", e.Repo, e.Team)
+	case SnapshotCollaborator:
+		return fmt.Sprintf("%s: permission of collaborator %s", e.Repo, e.User)
+	default:
+		return fmt.Sprintf("%s: %s", e.Repo, e.Kind)
+	}
+}
+
+// newRunID returns the id of a run, which is the time it started
+func newRunID() string {
+	return time.Now().UTC().Format("20060102T150405Z")
+}
+
+// SnapshotPath returns the path of the snapshot of the run
+func (s *ShepardBot) SnapshotPath(runID string) string {
+	return filepath.Join(s.policy.Snapshots, runID+".json")
+}
+
+// RunID returns the id of the current run, which is used to roll it back
+func (s *ShepardBot) RunID() string {
+	return s.snapshot.RunID
+}
+
+// record adds the prior state of a setting to the snapshot of the run, the snapshot is written to disk
+// before the setting is changed
+func (s *ShepardBot) record(entry SnapshotEntry) error {
+	for _, e := range s.snapshot.Entries {
+		if e.key() == entry.key() {
+			return nil
+		}
+	}
+	s.snapshot.Entries = append(s.snapshot.Entries, entry)
+
+	err := os.MkdirAll(s.policy.Snapshots, 0755)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(s.snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(s.SnapshotPath(s.snapshot.RunID), data, 0644)
+}
+
+// recordProtection captures the protection of the branch before it is changed
+func (s *ShepardBot) recordProtection(repo *github.Repository, branch *github.Branch) error {
+	current, err := s.currentProtection(repo, branch)
+	if err != nil {
+		return err
+	}
+
+	return s.record(SnapshotEntry{
+		Kind:       SnapshotProtection,
+		Repo:       repo.GetFullName(),
+		Branch:     branch.GetName(),
+		Protection: current,
+	})
+}
+
+// recordTeam captures the permission the team has on the repo before it is changed
+func (s *ShepardBot) recordTeam(repo *github.Repository, team *github.Team) error {
+	permission, err := s.teamPermission(repo, team)
+	if err != nil {
+		return err
+	}
+
+	return s.record(SnapshotEntry{
+		Kind:       SnapshotTeam,
+		Repo:       repo.GetFullName(),
+		TeamID:     team.GetID(),
+		Team:       team.GetSlug(),
+		Permission: permission,
+	})
+}
+
+// LoadSnapshot reads the snapshot of a run
+func (s *ShepardBot) LoadSnapshot(runID string) (*Snapshot, error) {
+	data, err := ioutil.ReadFile(s.SnapshotPath(runID))
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &Snapshot{}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, fmt.Errorf("unable to read snapshot %s: %v", runID, err)
+	}
+
+	if !strings.EqualFold(snapshot.Org, s.org.GetLogin()) {
+		return nil, fmt.Errorf("snapshot %s is of org %s", runID, snapshot.Org)
+	}
+
+	return snapshot, nil
+}
+
+// DoRestore puts the setting of the entry back to the state it was in before the run changed it
+func (s *ShepardBot) DoRestore(entry SnapshotEntry) error {
+	parts := strings.SplitN(entry.Repo, "/", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid repo %q in snapshot", entry.Repo)
+	}
+	owner, name := parts[0], parts[1]
+
+	switch entry.Kind {
+	case SnapshotProtection:
+		if entry.Protection == nil {
+			resp, err := s.gClient.Repositories.RemoveBranchProtection(s.ctx, owner, name, entry.Branch)
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				return nil
+			}
+			return err
+		}
+		return s.putProtection(owner, name, entry.Branch, entry.Protection)
+
+	case SnapshotTeam:
+		if entry.Permission == "" {
+			resp, err := s.gClient.Organizations.RemoveTeamRepo(s.ctx, entry.TeamID, owner, name)
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				return nil
+			}
+			return err
+		}
+
+		_, err := s.gClient.Organizations.AddTeamRepo(s.ctx, entry.TeamID, owner, name, &github.OrganizationAddTeamRepoOptions{
+			Permission: entry.Permission,
+		})
+		return err
+
+	case SnapshotCollaborator:
+		// outside collaborators are invited again, they regain access once they accept
+		_, err := s.gClient.Repositories.AddCollaborator(s.ctx, owner, name, entry.User, &github.RepositoryAddCollaboratorOptions{
+			Permission: entry.Permission,
+		})
+		return err
+
+	default:
+		return fmt.Errorf("unknown kind %q in snapshot", entry.Kind)
+	}
+}
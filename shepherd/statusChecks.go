@@ -0,0 +1,123 @@
+package shepherd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/go-github/github"
+)
+
+// Status check discovery modes, report lists the discovered contexts while apply requires them
+const (
+	DiscoverReport = "report"
+	DiscoverApply  = "apply"
+)
+
+// DiscoverSettings configures how the required status checks are inferred from recent CI activity
+type DiscoverSettings struct {
+	// Mode is report or apply, discovery is off when it is empty
+	Mode string `yaml:"mode"`
+	// Commits is how many of the latest commits of the branch are inspected
+	Commits int `yaml:"commits"`
+	// Threshold is the share of the inspected commits a context has to have passed on, 1 (the default)
+	// requires it to have passed on every commit
+	Threshold float64 `yaml:"threshold"`
+}
+
+func (d *DiscoverSettings) validate() error {
+	if d.Mode != "" && d.Mode != DiscoverReport && d.Mode != DiscoverApply {
+		return fmt.Errorf("protection status_checks discover mode must be %s or %s", DiscoverReport, DiscoverApply)
+	}
+
+	if d.Commits < 1 || d.Commits > 100 {
+		return errors.New("protection status_checks discover commits must be between 1 and 100")
+	}
+
+	if d.Threshold <= 0 || d.Threshold > 1 {
+		return errors.New("protection status_checks discover threshold must be above 0 and at most 1")
+	}
+	return nil
+}
+
+// DiscoverStatusChecks returns the status contexts that consistently reported success on the latest commits
+// of the branch, i.e. on at least the threshold share of them. Commits CI is still running on (or never ran
+// on) are skipped, so a fresh head commit doesn't hide the contexts
+func (s *ShepardBot) DiscoverStatusChecks(repo *github.Repository, branch *github.Branch, discover DiscoverSettings) ([]string, error) {
+	list, _, err := s.gClient.Repositories.ListCommits(s.ctx, *repo.Owner.Login, *repo.Name, &github.CommitsListOptions{
+		SHA:         branch.GetName(),
+		ListOptions: github.ListOptions{PerPage: discover.Commits},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	considered := 0
+	passed := map[string]int{}
+	for _, commit := range list {
+		status, _, err := s.gClient.Repositories.GetCombinedStatus(s.ctx, *repo.Owner.Login, *repo.Name, commit.GetSHA(), &github.ListOptions{PerPage: 100})
+		if err != nil {
+			return nil, err
+		}
+
+		// a commit without any status reports pending too
+		if status.GetState() == "pending" {
+			continue
+		}
+		considered++
+
+		// the statuses are the latest status of every context of the commit
+		for _, st := range status.Statuses {
+			if st.GetState() == "success" {
+				passed[st.GetContext()]++
+			}
+		}
+	}
+
+	return consistentContexts(passed, considered, discover.Threshold), nil
+}
+
+// consistentContexts returns the contexts that passed on at least the threshold share of the commits
+func consistentContexts(passed map[string]int, commits int, threshold float64) []string {
+	contexts := []string{}
+	for context, count := range passed {
+		if commits > 0 && float64(count) >= threshold*float64(commits) {
+			contexts = append(contexts, context)
+		}
+	}
+
+	sort.Strings(contexts)
+	return contexts
+}
+
+// ProposedStatusChecks returns the discovered status checks the branch doesn't require yet, when the
+// policy only reports them
+func (s *ShepardBot) ProposedStatusChecks(repo *github.Repository, branch *github.Branch) ([]string, error) {
+	protection, err := s.branchProtection(repo, branch.GetName())
+	if err != nil || protection.StatusChecks.Discover.Mode != DiscoverReport {
+		return nil, err
+	}
+
+	discovered, err := s.DiscoverStatusChecks(repo, branch, protection.StatusChecks.Discover)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.currentProtection(repo, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	var proposed []string
+	for _, context := range discovered {
+		if containsString(protection.StatusChecks.Contexts, context) {
+			continue
+		}
+		if current != nil && current.RequiredStatusChecks != nil && containsString(current.RequiredStatusChecks.Contexts, context) {
+			continue
+		}
+		proposed = append(proposed, context)
+	}
+
+	return proposed, nil
+}
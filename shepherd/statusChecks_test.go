@@ -0,0 +1,30 @@
+package shepherd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConsistentContexts(t *testing.T) {
+	passed := map[string]int{"ci/build": 10, "ci/lint": 9, "ci/flaky": 5}
+
+	tests := []struct {
+		name      string
+		commits   int
+		threshold float64
+		want      []string
+	}{
+		{name: "every commit", commits: 10, threshold: 1, want: []string{"ci/build"}},
+		{name: "most commits", commits: 10, threshold: 0.9, want: []string{"ci/build", "ci/lint"}},
+		{name: "half the commits", commits: 10, threshold: 0.5, want: []string{"ci/build", "ci/flaky", "ci/lint"}},
+		{name: "no commits", commits: 0, threshold: 1, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := consistentContexts(passed, tt.commits, tt.threshold); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("consistentContexts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
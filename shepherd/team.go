@@ -27,20 +27,65 @@ func (s *ShepardBot) retreiveTeams(orgName string) ([]*github.Team, error) {
 	return allTeams, nil
 }
 
-func (s *ShepardBot) setMaintainerTeam(maintainerTeamName string) error {
-	teams, err := s.retreiveTeams(s.org.GetLogin())
+// findTeam looks up a team of the org by name, the name can optionally be prefixed with the org (org/team)
+func (s *ShepardBot) findTeam(teamName string) (*github.Team, error) {
+	name := strings.TrimPrefix(strings.ToLower(teamName), strings.ToLower(s.org.GetLogin())+"/")
+
+	for _, team := range s.teams {
+		if strings.EqualFold(name, team.GetName()) || strings.EqualFold(name, team.GetSlug()) {
+			return team, nil
+		}
+	}
+
+	errMsg := fmt.Sprintf("Team (%s) not found within org", teamName)
+	return nil, errors.New(errMsg)
+}
+
+// checkTeams ensures every team the settings refer to exists
+func (s *ShepardBot) checkTeams(settings *Settings) error {
+	for _, team := range settings.teams() {
+		if _, err := s.findTeam(team); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maintainerTeam returns the team configured to maintain the repo
+func (s *ShepardBot) maintainerTeam(repo *github.Repository) (*github.Team, error) {
+	settings, err := s.Settings(repo)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	return s.findTeam(settings.Maintainer)
+}
+
+// teamMembers returns the logins of the members of the team
+func (s *ShepardBot) teamMembers(team *github.Team) ([]string, error) {
+	if members, ok := s.members[team.GetID()]; ok {
+		return members, nil
+	}
+
+	opt := &github.OrganizationListTeamMembersOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
 	}
 
-	for _, team := range teams {
-		if strings.EqualFold(maintainerTeamName, s.org.GetLogin()+"/"+*team.Name) {
-			s.maintainerTeam = team
-			return nil
+	var members []string
+	for {
+		users, resp, err := s.gClient.Organizations.ListTeamMembers(s.ctx, team.GetID(), opt)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			members = append(members, user.GetLogin())
+		}
+		if resp.NextPage == 0 {
+			break
 		}
+		opt.Page = resp.NextPage
 	}
 
-	errMsg := fmt.Sprintf("Team (%s) not found within org", maintainerTeamName)
-	err = errors.New(errMsg)
-	return err
+	s.members[team.GetID()] = members
+	return members, nil
 }
@@ -0,0 +1,161 @@
+package shepherd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/google/go-github/github"
+)
+
+// noAccess is the permission of a grant that removes a team from the repo
+const noAccess = "none"
+
+// TeamSettings grants teams of the org access to the repo, next to the maintainer team
+type TeamSettings struct {
+	// Grants maps a team to its permission on the repo, override blocks add to the grants of the defaults.
+	// A permission of none removes the team
+	Grants map[string]string `yaml:"grants"`
+	// Authoritative removes every team that is not granted access by the policy
+	Authoritative bool `yaml:"authoritative"`
+}
+
+// TeamAccess is a team whose permission on the repo differs from the policy, an empty permission means no access
+type TeamAccess struct {
+	Team    string
+	Current string
+	Desired string
+
+	team *github.Team
+}
+
+func (t *TeamSettings) validate() error {
+	for team, permission := range t.Grants {
+		if permission == noAccess {
+			continue
+		}
+
+		if err := validatePermission(permission); err != nil {
+			return fmt.Errorf("teams: %s: %v", team, err)
+		}
+	}
+	return nil
+}
+
+// desiredTeamAccess returns the permission of every team the policy mentions by team slug, the maintainer
+// team always has the maintainer permission
+func (s *ShepardBot) desiredTeamAccess(repo *github.Repository) (map[string]string, map[string]*github.Team, error) {
+	settings, err := s.Settings(repo)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	desired := map[string]string{}
+	teams := map[string]*github.Team{}
+
+	for name, permission := range settings.Teams.Grants {
+		team, err := s.findTeam(name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if permission == noAccess {
+			permission = ""
+		}
+		desired[team.GetSlug()] = permission
+		teams[team.GetSlug()] = team
+	}
+
+	maintainer, err := s.maintainerTeam(repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	desired[maintainer.GetSlug()] = settings.MaintainerPermission
+	teams[maintainer.GetSlug()] = maintainer
+
+	return desired, teams, nil
+}
+
+// CheckTeamAccess returns every team whose permission on the repo differs from the grants of the policy.
+// In authoritative mode teams without a grant are returned to be removed
+func (s *ShepardBot) CheckTeamAccess(repo *github.Repository) ([]TeamAccess, error) {
+	settings, err := s.Settings(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	desired, teams, err := s.desiredTeamAccess(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	maintainer, err := s.maintainerTeam(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []TeamAccess
+	for slug, permission := range desired {
+		// the maintainer team is managed by the team rule itself
+		if slug == maintainer.GetSlug() {
+			continue
+		}
+
+		current, err := s.teamPermission(repo, teams[slug])
+		if err != nil {
+			return nil, err
+		}
+
+		if current != permission {
+			changes = append(changes, TeamAccess{Team: slug, Current: current, Desired: permission, team: teams[slug]})
+		}
+	}
+
+	if settings.Teams.Authoritative {
+		opt := &github.ListOptions{PerPage: 100}
+		for {
+			repoTeams, resp, err := s.gClient.Repositories.ListTeams(s.ctx, *repo.Owner.Login, *repo.Name, opt)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, team := range repoTeams {
+				if _, ok := desired[team.GetSlug()]; ok {
+					continue
+				}
+				changes = append(changes, TeamAccess{Team: team.GetSlug(), Current: team.GetPermission(), team: team})
+			}
+
+			if resp.NextPage == 0 {
+				break
+			}
+			opt.Page = resp.NextPage
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Team < changes[j].Team })
+	return changes, nil
+}
+
+// DoTeamAccess grants, changes or revokes the access of the teams to the repo
+func (s *ShepardBot) DoTeamAccess(repo *github.Repository, changes []TeamAccess) error {
+	for _, change := range changes {
+		err := s.recordTeam(repo, change.team)
+		if err != nil {
+			return err
+		}
+
+		if change.Desired == "" {
+			_, err = s.gClient.Organizations.RemoveTeamRepo(s.ctx, change.team.GetID(), *repo.Owner.Login, *repo.Name)
+		} else {
+			_, err = s.gClient.Organizations.AddTeamRepo(s.ctx, change.team.GetID(), *repo.Owner.Login, *repo.Name, &github.OrganizationAddTeamRepoOptions{
+				Permission: change.Desired,
+			})
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package shepherd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPolicyTeams(t *testing.T) {
+	p := testPolicy(t, `
+org: my-org
+defaults:
+  maintainer: core
+  teams:
+    grants:
+      security: pull
+repos:
+  - match: "service-*"
+    teams:
+      grants:
+        qa: push
+  - match: "legacy-*"
+    maintainer: legacy
+`)
+
+	got, err := p.teams()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"core", "legacy", "qa", "security"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("teams() = %v, want %v", got, want)
+	}
+}
+
+func TestTeamSettingsValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		grants map[string]string
+		err    bool
+	}{
+		{name: "no grants"},
+		{name: "permissions", grants: map[string]string{"qa": "push", "security": "pull", "sre": "maintain"}},
+		{name: "revoked", grants: map[string]string{"contractors": "none"}},
+		{name: "unknown permission", grants: map[string]string{"qa": "write"}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := TeamSettings{Grants: tt.grants}
+			if err := settings.validate(); (err != nil) != tt.err {
+				t.Errorf("validate() error = %v, want error %v", err, tt.err)
+			}
+		})
+	}
+}